@@ -30,8 +30,9 @@ import (
 )
 
 var cmdflg = cli.Config{
-	Issuer: os.Getenv("CHAME_ISSUER"),
-	Secret: os.Getenv("CHAME_SECRET"),
+	Issuer:  os.Getenv("CHAME_ISSUER"),
+	Secret:  os.Getenv("CHAME_SECRET"),
+	KeyFile: os.Getenv("CHAME_KEY_FILE"),
 	Serve: struct {
 		Address string
 	}{
@@ -43,11 +44,15 @@ func main() {
 	flag.CommandLine.Parse([]string{"-logtostderr"})
 	defer glog.Flush()
 
+	store, err := cli.StoreFromConfig(cmdflg)
+	if err != nil {
+		glog.Exitf("failed to load a key: %v", err)
+	}
 	srv := &http.Server{
 		Addr: cmdflg.Serve.Address,
 		Handler: &chame.Chame{
 			Proxy: &chame.HTTPProxy{},
-			Store: cli.FixedStoreFromConfig(cmdflg),
+			Store: store,
 		},
 	}
 
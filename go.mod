@@ -3,13 +3,17 @@ module github.com/yosida95/chame
 go 1.22.0
 
 require (
+	github.com/go-jose/go-jose/v4 v4.0.5
 	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/golang/glog v1.2.4
 	github.com/google/go-cmp v0.7.0
 	github.com/spf13/cobra v1.9.1
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.34.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
+	golang.org/x/crypto v0.32.0 // indirect
 )
@@ -0,0 +1,98 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yosida95/chame/pkg/metadata" //lint:ignore SA1019 backward compatibility
+)
+
+// ServeCamo proxies URLs in the format of atmos/camo, which are either
+// "/<digest>/<hex-encoded URL>" or "/<digest>?url=<URL>", where digest is the
+// hex-encoded HMAC-SHA1 of the URL keyed by CamoKey.
+func (chame *Chame) ServeCamo(w http.ResponseWriter, userReq *http.Request) {
+	emitCommonHeaders(w.Header())
+	if chame.CamoKey == nil {
+		http.NotFound(w, userReq)
+		return
+	}
+	if !httpErrorIfMethodNotAllowed(w, userReq, http.MethodGet) {
+		return
+	}
+	origin, err := parseCamoPath(chame.CamoKey, userReq.URL)
+	if err != nil {
+		log.Printf("chame: %v", err)
+		http.NotFound(w, userReq)
+		return
+	}
+	ctx := userReq.Context()
+	//lint:ignore SA1019 backward compatibility
+	if time := metadata.Time(ctx); time.IsZero() {
+		ctx = metadata.New(ctx) //lint:ignore SA1019 backward compatibility
+	}
+
+	claims := &Claims{Token: Token{Subject: origin}}
+	if rc := chame.revocationChecker(); rc != nil {
+		if err := checkRevocation(ctx, rc, claims); errors.Is(err, ErrTokenRevoked) {
+			http.Error(w, "URL revoked", http.StatusGone)
+			return
+		} else if err != nil {
+			log.Printf("chame: %v", err)
+			httpError(w, http.StatusServiceUnavailable)
+			return
+		}
+	}
+	chame.proxy(w, userReq.WithContext(ctx), claims)
+}
+
+var errCamoDigest = errors.New("chame: camo digest mismatch")
+
+// parseCamoPath verifies a camo-style URL and returns the original URL in it.
+func parseCamoPath(key []byte, u *url.URL) (string, error) {
+	digest, encoded, hasURL := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	var origin string
+	if hasURL {
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil {
+			return "", errCamoDigest
+		}
+		origin = string(decoded)
+	} else {
+		origin = u.Query().Get("url")
+	}
+
+	mac, err := hex.DecodeString(digest)
+	if err != nil || !hmac.Equal(mac, camoDigest(key, origin)) {
+		return "", errCamoDigest
+	}
+	if parsed, err := url.Parse(origin); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", errors.New("chame: camo URL must be of http or https")
+	}
+	return origin, nil
+}
+
+func camoDigest(key []byte, origin string) []byte {
+	h := hmac.New(sha1.New, key)
+	h.Write([]byte(origin))
+	return h.Sum(nil)
+}
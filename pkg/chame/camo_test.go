@@ -0,0 +1,91 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestChame_ServeCamo(t *testing.T) {
+	key := []byte("0x24FEEDFACEDEADBEEFCAFE")
+	client, err := NewClient("https://camo.example.com", defaultIss, store)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, req.URL.String())
+		}),
+		Store:            store,
+		ExtraContentType: []string{"text/plain"},
+		CamoKey:          key,
+	}
+
+	const origin = "http://example.com/cat.png?size=large"
+	signed := client.SignCamo(key, origin)
+	digest, _, _ := strings.Cut(strings.TrimPrefix(signed, client.BaseURL()+"/"), "/")
+	for _, c := range []struct {
+		p    string
+		code int
+		body string
+	}{
+		{
+			p:    strings.TrimPrefix(signed, client.BaseURL()),
+			code: http.StatusOK,
+			body: origin,
+		},
+		{
+			p:    "/" + digest + "?url=" + url.QueryEscape(origin),
+			code: http.StatusOK,
+			body: origin,
+		},
+		{
+			p:    "/" + digest + "?url=" + url.QueryEscape("http://example.com/dog.png"),
+			code: http.StatusNotFound,
+		},
+		{
+			p:    strings.TrimPrefix(client.SignCamo([]byte("wrong"), origin), client.BaseURL()),
+			code: http.StatusNotFound,
+		},
+		{
+			p:    strings.TrimPrefix(client.SignCamo(key, "file:///etc/passwd"), client.BaseURL()),
+			code: http.StatusNotFound,
+		},
+	} {
+		w := httptest.NewRecorder()
+		chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.p, nil))
+		if w.Code != c.code {
+			t.Errorf("%q: expect %d, got %d", c.p, c.code, w.Code)
+			continue
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Errorf("expect %q, got %q", c.body, w.Body.String())
+		}
+	}
+
+	// camo-style URLs are not routed without CamoKey
+	chame.CamoKey = nil
+	w := httptest.NewRecorder()
+	chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(signed, client.BaseURL()), nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expect %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
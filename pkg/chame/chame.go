@@ -15,17 +15,21 @@
 package chame
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/yosida95/chame/pkg/metadata" //lint:ignore SA1019 backward compatibility
 )
 
@@ -41,6 +45,86 @@ type Chame struct {
 	// does not override the default list.
 	ExtraContentType []string
 
+	// Transcode maps a Content-Type of proxied images to how they are
+	// re-encoded when the client accepts a more efficient format. If
+	// Transcode is nil, images are passed through as is. Validate reports
+	// targets that have no ImageEncoder.
+	Transcode map[string]Transcoding
+	// ImageEncoder maps a Content-Type to an ImageEncoder used to transcode
+	// images into it, alongside the built-in encoders for image/png,
+	// image/jpeg and image/gif.
+	ImageEncoder map[string]ImageEncoder
+	// SanitizeSVG enables removing scripts, event handlers and external
+	// references from proxied image/svg+xml, so that they are harmless even
+	// if served without the Content-Security-Policy header set by chame.
+	// SVG documents that cannot be sanitized, such as those compressed with
+	// Content-Encoding, are rejected with 502 Bad Gateway.
+	SanitizeSVG bool
+	// StripMetadata enables removing EXIF, XMP, IPTC and textual metadata
+	// from proxied JPEG, PNG and WebP images. ICC color profiles are
+	// preserved, and data following the end of JPEG images is removed. JPEG
+	// and PNG images are processed as they arrive unless ApplyOrientation
+	// is set, while WebP images are held in memory.
+	StripMetadata bool
+	// ApplyOrientation makes StripMetadata rotate and flip JPEG images as
+	// specified by their EXIF orientation before removing it, so that they
+	// are not displayed rotated. Such images are re-encoded, and those
+	// larger than 2^26 pixels are rejected with 502 Bad Gateway.
+	ApplyOrientation bool
+	// ImageLimits restricts dimensions and the number of frames of proxied
+	// images to protect clients from decompression bombs. If ImageLimits is
+	// nil, images are not inspected. Images are checked as they arrive, and
+	// those whose header does not fit in the first 1 MiB are rejected.
+	ImageLimits *ImageLimits
+
+	// Placeholders are images served in place of error responses when
+	// proxying fails. IssuerPlaceholders overrides Placeholders for URLs
+	// signed by a specific issuer (the "iss" claim).
+	Placeholders       Placeholders
+	IssuerPlaceholders map[string]Placeholders
+
+	// Audience is the identifier of this Chame instance. If Audience is not
+	// empty, signed URLs must have the "aud" claim containing it.
+	Audience string
+	// AudienceFromHost makes the host name of each request used in place of
+	// Audience if Audience is empty.
+	AudienceFromHost bool
+
+	// Algorithms restricts JWS algorithms accepted in URLs signed by each
+	// issuer (the "iss" claim). URLs of issuers not in Algorithms are
+	// accepted with any of SupportedAlgorithms.
+	Algorithms map[string][]string
+
+	// Revocation is consulted to reject revoked URLs with 410 Gone. If
+	// Revocation is nil and Store implements RevocationChecker, Store is
+	// used instead.
+	Revocation RevocationChecker
+	// KeyObserver is notified of the key that verified each URL. If
+	// KeyObserver is nil and Store implements KeyObserver, Store is used
+	// instead.
+	KeyObserver KeyObserver
+
+	// CamoKey is the HMAC shared key of atmos/camo. If CamoKey is not nil,
+	// camo-style URLs are accepted outside of the path prefix of chame. See
+	// ServeCamo.
+	CamoKey []byte
+
+	// ShortIDs resolves short URLs of "/i/<short ID>" signed with
+	// Client.SignShort. If ShortIDs is nil, short URLs are not accepted.
+	ShortIDs ShortIDStore
+
+	// IssuerAliases maps short aliases of issuers to the issuers, which are
+	// used in place of the issuers in compact tokens. See
+	// SignOption.IssuerAlias.
+	IssuerAliases map[string]string
+
+	// RefererPolicies restricts pages that may load URLs signed by each
+	// issuer (the "iss" claim). Requests not allowed are rejected with 403
+	// Forbidden before fetching. Responses to URLs restricted by a policy,
+	// here or in the URL, have "Vary: Origin, Referer" and are marked
+	// private so that shared caches do not serve them to other pages.
+	RefererPolicies map[string]*RefererPolicy
+
 	ctypes map[string]struct{}
 	once   sync.Once
 }
@@ -60,6 +144,12 @@ func New(cfg *Config) http.Handler {
 	return cfg.applyInterceptors(chame)
 }
 
+// Validate reports an error if chame is misconfigured. It should be called
+// before chame starts serving requests.
+func (chame *Chame) Validate() error {
+	return chame.validateTranscode()
+}
+
 func (chame *Chame) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	p := req.URL.Path
 	if !strings.HasPrefix(p, "/") {
@@ -79,6 +169,8 @@ func (chame *Chame) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		chame.ServeHome(w, req)
 	case strings.HasPrefix(p, proxyPrefix):
 		chame.ServeProxy(w, req)
+	case chame.CamoKey != nil:
+		chame.ServeCamo(w, req)
 	default:
 		http.NotFound(w, req)
 	}
@@ -116,27 +208,75 @@ func (chame *Chame) ServeProxy(w http.ResponseWriter, userReq *http.Request) {
 		ctx = metadata.New(ctx) //lint:ignore SA1019 backward compatibility
 	}
 	signedURL := userReq.URL.Path[len(proxyPrefix):]
-	decoded, err := DecodeToken(ctx, chame.Store, signedURL)
+	if chame.ShortIDs != nil && isShortID(signedURL) {
+		token, err := chame.ShortIDs.GetToken(ctx, signedURL)
+		switch {
+		case err == nil:
+			signedURL = token
+		case errors.Is(err, ErrShortIDNotFound):
+			http.NotFound(w, userReq)
+			return
+		default:
+			log.Printf("chame: failed to resolve a short ID: %v", err)
+			httpError(w, http.StatusServiceUnavailable)
+			return
+		}
+	}
+	claims, err := decodeToken(ctx, chame.Store, signedURL, &decodeOptions{
+		checkAlg:      chame.acceptsAlgorithm,
+		revocation:    chame.revocationChecker(),
+		issuerAliases: chame.IssuerAliases,
+		audience:      chame.audience(userReq),
+		keyObserver:   chame.keyObserver(),
+	})
 	if err != nil {
-		var jwtErr *jwt.ValidationError
-		if errors.As(err, &jwtErr) {
-			switch {
-			case jwtErr.Errors&jwt.ValidationErrorMalformed != 0:
-				http.NotFound(w, userReq)
-				return
-			case jwtErr.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
-				http.Error(w, "URL not valid yet", http.StatusNotFound)
-				return
-			case jwtErr.Errors&jwt.ValidationErrorExpired != 0:
-				http.Error(w, "URL expired", http.StatusGone)
-				return
-			}
+		switch {
+		case errors.Is(err, ErrTokenRevoked):
+			http.Error(w, "URL revoked", http.StatusGone)
+			return
+		case errors.Is(err, errRevocationUnavailable), errors.Is(err, errKeyUnavailable):
+			log.Printf("chame: DecodeToken error: %v", err)
+			httpError(w, http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, ErrTokenMalformed):
+			http.NotFound(w, userReq)
+			return
+		case errors.Is(err, ErrUnknownKey):
+			log.Printf("chame: DecodeToken error: %v", err)
+			http.NotFound(w, userReq)
+			return
+		case errors.Is(err, ErrTokenNotYetValid):
+			http.Error(w, "URL not valid yet", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrTokenExpired):
+			http.Error(w, "URL expired", http.StatusGone)
+			return
+		case errors.Is(err, ErrTokenAudience):
+			log.Printf("chame: %v", err)
+			http.Error(w, "URL not valid for this host", http.StatusForbidden)
+			return
 		}
 		log.Printf("chame: DecodeToken error: %v", err)
 		httpError(w, http.StatusBadRequest)
 		return
 	}
-	reqUrl, err := url.Parse(decoded)
+	if chame.hasRefererPolicy(claims) {
+		// NOTE(yosida95): caches in front of chame must not serve a
+		// response to pages other than the one it was made for.
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Referer")
+		if !chame.checkReferer(userReq, claims) {
+			http.Error(w, "URL not allowed for this page", http.StatusForbidden)
+			return
+		}
+	}
+	chame.proxy(w, userReq.WithContext(ctx), claims)
+}
+
+// proxy fetches the URL in verified claims through Proxy and writes the
+// response to w.
+func (chame *Chame) proxy(w http.ResponseWriter, userReq *http.Request, claims *Claims) {
+	reqUrl, err := url.Parse(claims.Subject)
 	if err != nil {
 		log.Printf("chame: malformed URL: %v", err)
 		httpError(w, http.StatusBadRequest)
@@ -146,12 +286,68 @@ func (chame *Chame) ServeProxy(w http.ResponseWriter, userReq *http.Request) {
 	filtered := make(http.Header)
 	copyHeadersOnlyIn(filtered, userReq.Header, passThroughReqHeaders)
 
-	w = chame.newResponseWriter(w)
-	chame.Proxy.Do(w, &ProxyRequest{
-		Context: ctx,
+	rw := chame.newResponseWriter(w)
+	rw.privateCache = chame.hasRefererPolicy(claims)
+	rw.filters = func(hdr http.Header, ctype string) []bodyFilter {
+		return chame.bodyFilters(hdr, ctype, userReq.Header, rw.checkCT)
+	}
+	if len(claims.ContentType) > 0 {
+		rw.checkCT = func(ctype string) bool {
+			return chame.checkContentType(ctype) && matchContentType(claims.ContentType, ctype)
+		}
+	}
+	rw.placeholder = func(class FailureClass) *Placeholder {
+		return chame.lookupPlaceholder(claims.Issuer, class)
+	}
+	chame.Proxy.Do(rw, &ProxyRequest{
+		Context: context.WithValue(userReq.Context(), claimsKey{}, claims),
 		URL:     reqUrl,
 		Header:  filtered,
+		Claims:  claims,
 	})
+	rw.close()
+}
+
+// audience returns the audience expected for req, or an empty string if the
+// "aud" claim is not validated.
+func (chame *Chame) audience(req *http.Request) string {
+	if chame.Audience != "" || !chame.AudienceFromHost {
+		return chame.Audience
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+func (chame *Chame) revocationChecker() RevocationChecker {
+	if chame.Revocation != nil {
+		return chame.Revocation
+	}
+	rc, _ := chame.Store.(RevocationChecker)
+	return rc
+}
+
+func (chame *Chame) keyObserver() KeyObserver {
+	if chame.KeyObserver != nil {
+		return chame.KeyObserver
+	}
+	ko, _ := chame.Store.(KeyObserver)
+	return ko
+}
+
+func (chame *Chame) acceptsAlgorithm(iss string, alg string) bool {
+	algs, ok := chame.Algorithms[iss]
+	if !ok {
+		return true
+	}
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
 }
 
 // checkContentType checks if the given ctype is allowed to be proxied. ctype
@@ -174,6 +370,19 @@ func (chame *Chame) checkContentType(ctype string) bool {
 	return found
 }
 
+// matchContentType reports whether ctype matches any of patterns. A pattern
+// may be a wildcard of the form "type/*" or "*/*".
+func matchContentType(patterns []string, ctype string) bool {
+	major, _, _ := strings.Cut(ctype, "/")
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == ctype || pattern == "*/*" || pattern == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
 var defaultContentType []string
 
 func init() {
@@ -232,11 +441,28 @@ var DefaultContentType = []string{
 
 type responseWriter struct {
 	http.ResponseWriter
-	headers http.Header
-	checkCT func(string) bool
+	headers     http.Header
+	checkCT     func(string) bool
+	filters     func(http.Header, string) []bodyFilter
+	placeholder func(FailureClass) *Placeholder
+	// privateCache prevents shared caches from storing the response.
+	privateCache bool
 
 	once    sync.Once
 	discard bool
+
+	// code, pending and buf hold the response until close while bodyFilters
+	// are being applied.
+	code    int
+	pending []bodyFilter
+	buf     bytes.Buffer
+
+	// stream is set in place of pending while bodyFilters process the body
+	// as a stream, whose result is sent to streamErr. committed is set once
+	// the filtered body starts to be written.
+	stream    *io.PipeWriter
+	streamErr <-chan error
+	committed bool
 }
 
 var _ http.ResponseWriter = (*responseWriter)(nil)
@@ -267,6 +493,9 @@ func (w *responseWriter) WriteHeader(code int) {
 		dest := w.ResponseWriter.Header()
 		emitCommonHeaders(dest)
 		copyHeadersOnlyIn(dest, w.headers, passThroughRespHeaders)
+		if w.privateCache {
+			dest.Set("Cache-Control", privateCacheControl(dest.Get("Cache-Control")))
+		}
 
 		ctype := dest.Get(headerKeyContentType)
 		parsed, _, err := mime.ParseMediaType(ctype)
@@ -274,6 +503,9 @@ func (w *responseWriter) WriteHeader(code int) {
 			switch {
 			case parsed == "text/plain" && code >= 400:
 				// special handling for error responses
+				if w.servePlaceholder(classifyStatus(code), code) {
+					return
+				}
 			case ctype == "" && code == http.StatusNotModified:
 				w.discard = true
 				dest.Del(cl)
@@ -281,7 +513,29 @@ func (w *responseWriter) WriteHeader(code int) {
 				log.Printf("chame: unacceptable Content-Type: %q", ctype)
 				w.discard = true
 				dest.Del(cl)
-				httpError(w.ResponseWriter, http.StatusBadGateway)
+				if !w.servePlaceholder(FailureRejected, http.StatusBadGateway) {
+					httpError(w.ResponseWriter, http.StatusBadGateway)
+				}
+				return
+			}
+		} else if w.filters != nil {
+			filters := w.filters(dest, parsed)
+			switch {
+			case len(filters) == 0:
+			case code == http.StatusOK && isIdentityEncoding(dest):
+				w.code = code
+				if canStream(filters) {
+					w.startStream(filters)
+				} else {
+					w.pending = filters
+				}
+				return
+			case hasBody(code) && requiresFilter(filters):
+				// NOTE(yosida95): the origin chooses Content-Encoding
+				// regardless of what we asked for, so it must not be
+				// a way to bypass filters.
+				log.Printf("chame: unable to filter a response of %d with Content-Encoding %q", code, dest.Get("Content-Encoding"))
+				w.fail(http.StatusBadGateway)
 				return
 			}
 		}
@@ -294,5 +548,156 @@ func (w *responseWriter) Write(p []byte) (int, error) {
 	if w.discard {
 		return len(p), nil
 	}
+	if w.stream != nil {
+		return w.stream.Write(p)
+	}
+	if w.pending != nil {
+		if w.buf.Len()+len(p) <= maxFilterBodySize {
+			return w.buf.Write(p)
+		}
+		if err := w.bypassFilters(); err != nil {
+			return 0, err
+		}
+		if w.discard {
+			return len(p), nil
+		}
+		if w.stream != nil {
+			return w.stream.Write(p)
+		}
+	}
 	return w.ResponseWriter.Write(p)
 }
+
+// bypassFilters gives up applying pending filters to a response body too
+// large to buffer. Required filters that can process the body as a stream
+// continue to be applied to it, and if any other is required, the response
+// is replaced with an error.
+func (w *responseWriter) bypassFilters() error {
+	var required []bodyFilter
+	for _, f := range w.pending {
+		if f.required {
+			required = append(required, f)
+		}
+	}
+	w.pending = nil
+	if len(required) > 0 {
+		if !canStream(required) {
+			log.Printf("chame: response body too large to filter")
+			w.fail(http.StatusBadGateway)
+			return nil
+		}
+		w.startStream(required)
+		_, err := w.stream.Write(w.buf.Bytes())
+		w.buf = bytes.Buffer{}
+		return err
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// startStream starts applying filters to the response body as a stream.
+func (w *responseWriter) startStream(filters []bodyFilter) {
+	for _, f := range filters {
+		if !f.inspectOnly {
+			w.ResponseWriter.Header().Del("Content-Length")
+			break
+		}
+	}
+	w.stream, w.streamErr = pipeFilters(streamSink{w}, filters)
+}
+
+// streamSink writes a filtered response body to the client, sending the
+// response header on the first write.
+type streamSink struct {
+	w *responseWriter
+}
+
+func (s streamSink) Write(p []byte) (int, error) {
+	s.w.commit()
+	return s.w.ResponseWriter.Write(p)
+}
+
+func (w *responseWriter) commit() {
+	if !w.committed {
+		w.committed = true
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+}
+
+// closeStream waits for the filters applied by startStream. Once the
+// response header is sent, a failure can only be signaled by aborting the
+// response.
+func (w *responseWriter) closeStream() {
+	w.stream.Close()
+	err := <-w.streamErr
+	w.stream = nil
+	switch {
+	case err == nil:
+		w.commit()
+	case !w.committed:
+		log.Printf("chame: failed to filter response body: %v", err)
+		w.fail(http.StatusBadGateway)
+	default:
+		log.Printf("chame: failed to filter response body: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// close applies pending filters to the held response body and flushes it. It
+// must be called after Proxy.Do returns.
+func (w *responseWriter) close() {
+	if w.stream != nil {
+		w.closeStream()
+		return
+	}
+	if w.pending == nil {
+		return
+	}
+	filters := w.pending
+	w.pending = nil
+
+	dest := w.ResponseWriter.Header()
+	body := w.buf.Bytes()
+	for _, f := range filters {
+		var err error
+		if body, err = f.run(dest, body); err != nil {
+			log.Printf("chame: failed to filter response body: %v", err)
+			w.fail(http.StatusBadGateway)
+			return
+		}
+	}
+	dest.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.code)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		log.Printf("chame: failed to forward filtered response to the client: %v", err)
+	}
+}
+
+// fail discards headers copied from the origin and responds with an error.
+func (w *responseWriter) fail(code int) {
+	w.discard = true
+	dest := w.ResponseWriter.Header()
+	for _, key := range passThroughRespHeaders {
+		dest.Del(key)
+	}
+	if !w.servePlaceholder(FailureRejected, code) {
+		httpError(w.ResponseWriter, code)
+	}
+}
+
+// servePlaceholder responds with a placeholder image in place of an error
+// response of code. It reports whether a placeholder is found.
+func (w *responseWriter) servePlaceholder(class FailureClass, code int) bool {
+	if w.placeholder == nil {
+		return false
+	}
+	p := w.placeholder(class)
+	if p == nil {
+		return false
+	}
+	w.discard = true
+	p.serve(w.ResponseWriter, class, code, w.privateCache)
+	return true
+}
@@ -1,6 +1,7 @@
 package chame
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -23,6 +24,18 @@ func (key bytesKey) GetVerifyingKey(iss, kid string) (any, error) {
 	return []byte(key), nil
 }
 
+func signedPath(t *testing.T, url string) string {
+	t.Helper()
+	signed, err := EncodeToken(context.Background(), keyStore, &Token{
+		Issuer:  "https://chame.example.net",
+		Subject: url,
+	}, "")
+	if err != nil {
+		t.Fatalf("failed to sign %q: %v", url, err)
+	}
+	return proxyPrefix + signed
+}
+
 type proxyfunc func(http.ResponseWriter, *ProxyRequest)
 
 func (fn proxyfunc) Do(w http.ResponseWriter, req *ProxyRequest) {
@@ -140,6 +153,33 @@ func TestChame_ServeProxy(t *testing.T) {
 	}
 }
 
+func TestChame_ServeProxyClaims(t *testing.T) {
+	var fromReq, fromCtx *Claims
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			fromReq, fromCtx = req.Claims, ClaimsFromContext(req.Context)
+			w.Header().Set("Content-Type", "text/plain")
+		}),
+		Store:            keyStore,
+		ExtraContentType: []string{"text/plain"},
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.jpeg"), nil)
+	chame.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect %d, got %d", http.StatusOK, w.Code)
+	}
+	if fromReq == nil || fromReq != fromCtx {
+		t.Fatalf("claims are not attached: %v, %v", fromReq, fromCtx)
+	}
+	if fromReq.Issuer != "https://chame.example.net" || fromReq.Algorithm != "HS256" {
+		t.Errorf("unexpected claims: %+v", fromReq)
+	}
+	if ClaimsFromContext(context.Background()) != nil {
+		t.Errorf("expect nil claims for a bare context")
+	}
+}
+
 func TestResponseWriter(t *testing.T) {
 	chame := &Chame{
 		ContentType: []string{"image/jpeg"},
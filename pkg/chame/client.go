@@ -16,9 +16,11 @@ package chame
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net/url"
 	"path"
+	"sync"
 	"time"
 )
 
@@ -52,16 +54,44 @@ func NewClient(baseUrl string, issuer string, store Store) (*Client, error) {
 func (cli *Client) BaseURL() string { return cli.baseUrl }
 
 func (cli *Client) Sign(ctx context.Context, url string, opts SignOption) (string, error) {
-	if opts.NotAfter.IsZero() && !opts.Expiry.IsZero() {
-		opts.NotAfter = opts.Expiry
+	signer, err := newTokenSigner(ctx, cli.store, cli.issuer, opts.JwtKid, opts.Algorithm, opts.Encryption)
+	if err != nil {
+		return "", err
 	}
+	return cli.sign(signer, url, &opts)
+}
 
-	signed, err := EncodeToken(ctx, cli.store, &Token{
-		Issuer:    cli.issuer,
-		Subject:   url,
-		NotBefore: toNumericDate(opts.NotBefore),
-		ExpiresAt: toNumericDate(opts.NotAfter),
-	}, opts.JwtKid)
+func (cli *Client) sign(signer *tokenSigner, rawurl string, opts *SignOption) (string, error) {
+	// NOTE(yosida95): Chame rejects URLs that url.Parse rejects.
+	if _, err := url.Parse(rawurl); err != nil {
+		return "", fmt.Errorf("chame: malformed URL: %w", err)
+	}
+	notAfter := opts.NotAfter
+	if notAfter.IsZero() && !opts.Expiry.IsZero() {
+		notAfter = opts.Expiry
+	}
+
+	claims := &Claims{
+		Token: Token{
+			Issuer:    cli.issuer,
+			Subject:   rawurl,
+			NotBefore: toNumericDate(opts.NotBefore),
+			ExpiresAt: toNumericDate(notAfter),
+			Audience:  opts.Audience,
+			ID:        opts.JwtID,
+		},
+		ContentType: opts.ContentType,
+		Referer:     opts.Referer,
+	}
+	var (
+		signed string
+		err    error
+	)
+	if opts.Compact {
+		signed, err = signer.signCompact(claims, opts.IssuerAlias)
+	} else {
+		signed, err = signer.sign(claims)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -69,10 +99,135 @@ func (cli *Client) Sign(ctx context.Context, url string, opts SignOption) (strin
 	return cli.baseUrl + proxyPrefix + signed, nil
 }
 
+// SignResult is the result of signing a URL with SignAll.
+type SignResult struct {
+	// URL is the signed URL, which is empty if Err is not nil.
+	URL string
+	Err error
+}
+
+// SignAllOption customizes SignAll in addition to SignOption, which applies
+// to all the URLs.
+type SignAllOption struct {
+	SignOption
+	// Parallelism is the maximum number of URLs signed concurrently. If it
+	// is less than 2, URLs are signed sequentially.
+	Parallelism int
+	// Dedup signs each distinct URL only once and shares the result among
+	// its occurrences.
+	Dedup bool
+}
+
+// SignAll signs urls with the same options, looking up the signing key only
+// once. The results are in the same order as urls. A failure to sign a URL is
+// reported in its SignResult and does not affect the others, while a failure
+// to retrieve the key is reported for all of them.
+func (cli *Client) SignAll(ctx context.Context, urls []string, opts SignAllOption) []SignResult {
+	results := make([]SignResult, len(urls))
+	signer, err := newTokenSigner(ctx, cli.store, cli.issuer, opts.JwtKid, opts.Algorithm, opts.Encryption)
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	// indices are the positions in urls to sign, and dups maps the rest to
+	// the position whose result they share.
+	indices := make([]int, 0, len(urls))
+	var dups map[int]int
+	if opts.Dedup {
+		seen := make(map[string]int, len(urls))
+		dups = make(map[int]int)
+		for i, url := range urls {
+			if j, ok := seen[url]; ok {
+				dups[i] = j
+				continue
+			}
+			seen[url] = i
+			indices = append(indices, i)
+		}
+	} else {
+		for i := range urls {
+			indices = append(indices, i)
+		}
+	}
+
+	signOne := func(i int) {
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].URL, results[i].Err = cli.sign(signer, urls[i], &opts.SignOption)
+	}
+	if opts.Parallelism < 2 {
+		for _, i := range indices {
+			signOne(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, opts.Parallelism)
+		for _, i := range indices {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				signOne(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	for i, j := range dups {
+		results[i] = results[j]
+	}
+	return results
+}
+
+// SignCamo returns a URL of camo-style "/<digest>/<hex-encoded URL>" format
+// signed with key, which is accepted by Chame with the same CamoKey.
+func (cli *Client) SignCamo(key []byte, url string) string {
+	return cli.baseUrl + "/" + hex.EncodeToString(camoDigest(key, url)) + "/" + hex.EncodeToString([]byte(url))
+}
+
 type SignOption struct {
 	JwtKid    string
 	NotBefore time.Time
 	NotAfter  time.Time
+	// JwtID is the "jti" claim that identifies the signed URL to revoke it
+	// later. See NewTokenID.
+	JwtID string
+	// Algorithm is the JWS algorithm to sign the URL with, such as "RS384"
+	// or "PS256". If empty, the algorithm of the key from Store or the
+	// default for the type of the key is used.
+	Algorithm string
+	// ContentType narrows Content-Type values allowed to be proxied for the
+	// URL. The proxied content must match both this list and the list
+	// configured on Chame. An entry may be a wildcard such as "image/*".
+	ContentType []string
+	// Audience restricts Chame instances that accept the signed URL to those
+	// whose audience is in the list (the "aud" claim).
+	Audience []string
+	// Referer restricts pages that may load the URL by their Origin or
+	// Referer header. Chame.RefererPolicies is also enforced if any.
+	Referer *RefererPolicy
+	// Encryption makes the signed URL encrypted so that the original URL is
+	// not readable by viewers. The Store of Client must implement
+	// EncryptingStore.
+	Encryption Encryption
+	// Compact signs the URL in the compact token profile, which is much
+	// shorter than JWT. Compact tokens cannot be encrypted.
+	Compact bool
+	// IssuerAlias is encoded in place of the issuer in compact tokens to
+	// shorten them further. Chame must map it to the issuer in
+	// IssuerAliases.
+	IssuerAlias string
+	// ShortIDKey is the HMAC key to derive short IDs from tokens in
+	// SignShort. If nil, short IDs are random.
+	ShortIDKey []byte
 
 	// Deprecated: use NotAfter
 	Expiry time.Time
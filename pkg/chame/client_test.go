@@ -16,6 +16,9 @@ package chame
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -58,3 +61,176 @@ func TestClientSign(t *testing.T) {
 		}
 	}
 }
+
+func TestClientSign_ContentType(t *testing.T) {
+	client, err := NewClient("https://chame.yosida95.com", defaultIss, store)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", req.URL.Query().Get("ctype"))
+			w.Write([]byte("image"))
+		}),
+		Store: store,
+	}
+	for _, c := range []struct {
+		allowed []string
+		ctype   string
+		code    int
+	}{
+		{
+			allowed: nil,
+			ctype:   "image/jpeg",
+			code:    http.StatusOK,
+		},
+		{
+			allowed: []string{"image/png", "image/jpeg"},
+			ctype:   "image/jpeg",
+			code:    http.StatusOK,
+		},
+		{
+			allowed: []string{"image/png"},
+			ctype:   "image/jpeg",
+			code:    http.StatusBadGateway,
+		},
+		{
+			allowed: []string{"image/*"},
+			ctype:   "image/gif",
+			code:    http.StatusOK,
+		},
+		{
+			// not allowed by Chame.ContentType
+			allowed: []string{"*/*"},
+			ctype:   "text/html",
+			code:    http.StatusBadGateway,
+		},
+	} {
+		signed, err := client.Sign(context.Background(), "https://example.com/?ctype="+url.QueryEscape(c.ctype), SignOption{
+			JwtKid:      defaultKid,
+			ContentType: c.allowed,
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		w := httptest.NewRecorder()
+		chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signed, nil))
+		if w.Code != c.code {
+			t.Errorf("%q, %q: expect %d, got %d", c.allowed, c.ctype, c.code, w.Code)
+		}
+	}
+}
+
+func TestClientSign_Audience(t *testing.T) {
+	client, err := NewClient("https://chame.yosida95.com", defaultIss, store)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	proxy := proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("image"))
+	})
+	for _, c := range []struct {
+		chame *Chame
+		host  string
+		aud   []string
+		code  int
+	}{
+		{
+			chame: &Chame{Proxy: proxy, Store: store},
+			host:  "a.example.com",
+			aud:   nil,
+			code:  http.StatusOK,
+		},
+		{
+			chame: &Chame{Proxy: proxy, Store: store, AudienceFromHost: true},
+			host:  "a.example.com:8443",
+			aud:   []string{"a.example.com"},
+			code:  http.StatusOK,
+		},
+		{
+			chame: &Chame{Proxy: proxy, Store: store, AudienceFromHost: true},
+			host:  "images.example.com",
+			aud:   []string{"Images.Example.com"},
+			code:  http.StatusOK,
+		},
+		{
+			chame: &Chame{Proxy: proxy, Store: store, AudienceFromHost: true},
+			host:  "b.example.com",
+			aud:   []string{"a.example.com"},
+			code:  http.StatusForbidden,
+		},
+		{
+			chame: &Chame{Proxy: proxy, Store: store, AudienceFromHost: true},
+			host:  "a.example.com",
+			aud:   nil,
+			code:  http.StatusForbidden,
+		},
+		{
+			chame: &Chame{Proxy: proxy, Store: store, Audience: "product-b"},
+			host:  "a.example.com",
+			aud:   []string{"product-a", "product-b"},
+			code:  http.StatusOK,
+		},
+	} {
+		signed, err := client.Sign(context.Background(), "https://example.com/cat.png", SignOption{
+			JwtKid:   defaultKid,
+			Audience: c.aud,
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, signed, nil)
+		req.Host = c.host
+		c.chame.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%q, %q: expect %d, got %d", c.host, c.aud, c.code, w.Code)
+		}
+	}
+}
+
+func TestClientSignAll(t *testing.T) {
+	client, err := NewClient("https://chame.yosida95.com", defaultIss, store)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	urls := []string{
+		"https://example.com/foo.png",
+		"https://example.com/bar.png",
+		"https://example.com/%zz",
+		"https://example.com/foo.png",
+	}
+	for _, opts := range []SignAllOption{
+		{SignOption: SignOption{JwtKid: defaultKid}},
+		{SignOption: SignOption{JwtKid: defaultKid}, Parallelism: 4},
+		{SignOption: SignOption{JwtKid: defaultKid}, Dedup: true},
+		{SignOption: SignOption{JwtKid: defaultKid}, Parallelism: 2, Dedup: true},
+	} {
+		results := client.SignAll(context.Background(), urls, opts)
+		if len(results) != len(urls) {
+			t.Fatalf("expected %d results, have %d", len(urls), len(results))
+		}
+		for i, url := range urls {
+			expected, err := client.Sign(context.Background(), url, opts.SignOption)
+			if (err != nil) != (results[i].Err != nil) {
+				t.Errorf("%+v: %d: expected error %v, have %v", opts, i, err, results[i].Err)
+				continue
+			}
+			if results[i].URL != expected {
+				t.Errorf("%+v: %d: expected %q, have %q", opts, i, expected, results[i].URL)
+			}
+		}
+	}
+
+	results := client.SignAll(context.Background(), urls, SignAllOption{
+		SignOption: SignOption{JwtKid: "unknown"},
+	})
+	for i, res := range results {
+		if res.Err == nil || res.URL != "" {
+			t.Errorf("%d: expected error, have %+v", i, res)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// The compact token profile is an alternative to JWT which encodes claims
+// into a binary form signed with the same keys and algorithms as JWS. A
+// compact token consists of two base64url-encoded segments joined with ".",
+// the payload and the signature of the encoded payload.
+//
+// The payload starts with the version byte, the algorithm and the key ID,
+// followed by claims in the form of a tag byte and its value. Strings are
+// prefixed with their length in uvarint, and times are Unix times in
+// uvarint.
+const compactVersion = 1
+
+// compactAlgorithms are the algorithms identified by their index plus one.
+// New algorithms must be appended so as not to change the existing IDs.
+var compactAlgorithms = []string{
+	"HS256", "HS384", "HS512",
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+const (
+	compactTagIssuer       = 0x01
+	compactTagIssuerAlias  = 0x02
+	compactTagSubject      = 0x03
+	compactTagSubjectHTTP  = 0x04
+	compactTagSubjectTLS   = 0x05
+	compactTagExpiresAt    = 0x06
+	compactTagNotBefore    = 0x07
+	compactTagIssuedAt     = 0x08
+	compactTagAudience     = 0x09
+	compactTagID           = 0x0a
+	compactTagContentType  = 0x0b
+	compactTagRefererHost  = 0x0c
+	compactTagReferer      = 0x0d
+	compactTagRefererEmpty = 0x0e
+)
+
+var errMalformedCompact = &tokenError{ErrTokenMalformed, errors.New("chame: malformed compact token")}
+
+// isCompactToken reports whether tokenString is in the compact profile.
+func isCompactToken(tokenString string) bool {
+	return strings.Count(tokenString, ".") == 1
+}
+
+func compactAlgorithmID(alg string) (byte, bool) {
+	for i, a := range compactAlgorithms {
+		if a == alg {
+			return byte(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+func appendCompactString(b []byte, tag byte, s string) []byte {
+	b = append(b, tag)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendCompactTime(b []byte, tag byte, t *jwt.NumericDate) []byte {
+	if t == nil {
+		return b
+	}
+	b = append(b, tag)
+	return binary.AppendUvarint(b, uint64(t.Unix()))
+}
+
+// signCompact signs token in the compact profile. If alias is not empty, it
+// is encoded in place of the issuer.
+func (signer *tokenSigner) signCompact(token *Claims, alias string) (string, error) {
+	if signer.encrypter != nil {
+		return "", errors.New("chame: compact tokens cannot be encrypted")
+	}
+	algID, ok := compactAlgorithmID(signer.mech.Alg())
+	if !ok {
+		return "", fmt.Errorf("chame: algorithm %q is not supported", signer.mech.Alg())
+	}
+	b := []byte{compactVersion, algID}
+	b = binary.AppendUvarint(b, uint64(len(signer.kid)))
+	b = append(b, signer.kid...)
+
+	if alias != "" {
+		b = appendCompactString(b, compactTagIssuerAlias, alias)
+	} else {
+		b = appendCompactString(b, compactTagIssuer, token.Issuer)
+	}
+	switch sub := token.Subject; {
+	case strings.HasPrefix(sub, "https://"):
+		b = appendCompactString(b, compactTagSubjectTLS, sub[len("https://"):])
+	case strings.HasPrefix(sub, "http://"):
+		b = appendCompactString(b, compactTagSubjectHTTP, sub[len("http://"):])
+	default:
+		b = appendCompactString(b, compactTagSubject, sub)
+	}
+	b = appendCompactTime(b, compactTagExpiresAt, token.ExpiresAt)
+	b = appendCompactTime(b, compactTagNotBefore, token.NotBefore)
+	b = appendCompactTime(b, compactTagIssuedAt, token.IssuedAt)
+	for _, aud := range token.Audience {
+		b = appendCompactString(b, compactTagAudience, aud)
+	}
+	if token.ID != "" {
+		b = appendCompactString(b, compactTagID, token.ID)
+	}
+	for _, ctype := range token.ContentType {
+		b = appendCompactString(b, compactTagContentType, ctype)
+	}
+	if ref := token.Referer; ref != nil {
+		// NOTE(yosida95): a policy without hosts is distinguished from
+		// the absence of the policy by the tag without value.
+		if len(ref.Hosts) == 0 {
+			b = append(b, compactTagReferer)
+		}
+		for _, host := range ref.Hosts {
+			b = appendCompactString(b, compactTagRefererHost, host)
+		}
+		if ref.AllowEmpty {
+			b = append(b, compactTagRefererEmpty)
+		}
+	}
+
+	payload := jwt.EncodeSegment(b)
+	sig, err := signer.mech.Sign(payload, signer.key)
+	if err != nil {
+		return "", fmt.Errorf("chame: failed to sign a token: %w", err)
+	}
+	return payload + "." + sig, nil
+}
+
+// compactReader reads values of the compact profile.
+type compactReader struct {
+	*bytes.Reader
+}
+
+func (r compactReader) string() (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return "", errMalformedCompact
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return string(b), nil
+}
+
+func (r compactReader) time() (*jwt.NumericDate, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > 1<<62 {
+		return nil, errMalformedCompact
+	}
+	return jwt.NewNumericDate(time.Unix(int64(n), 0)), nil
+}
+
+// parseCompact decodes the payload of a compact token without verification.
+// The issuer is left empty if the payload has an alias of it instead.
+func parseCompact(b []byte) (claims *Claims, alg string, alias string, err error) {
+	if len(b) < 2 || b[0] != compactVersion {
+		return nil, "", "", errMalformedCompact
+	}
+	if b[1] == 0 || int(b[1]) > len(compactAlgorithms) {
+		return nil, "", "", &tokenError{ErrUnsupportedAlgorithm, errors.New("chame: unknown algorithm of compact token")}
+	}
+	alg = compactAlgorithms[b[1]-1]
+	r := compactReader{bytes.NewReader(b[2:])}
+	claims = &Claims{}
+	if claims.KeyID, err = r.string(); err != nil {
+		return nil, "", "", err
+	}
+	seen := make(map[byte]bool)
+	for r.Len() > 0 {
+		tag, _ := r.ReadByte()
+		switch tag {
+		case compactTagIssuer, compactTagIssuerAlias,
+			compactTagSubject, compactTagSubjectHTTP, compactTagSubjectTLS,
+			compactTagExpiresAt, compactTagNotBefore, compactTagIssuedAt,
+			compactTagID:
+			// NOTE(yosida95): singular claims must not be repeated, and the
+			// issuer and the subject must not be in multiple forms.
+			kind := tag
+			switch tag {
+			case compactTagIssuerAlias:
+				kind = compactTagIssuer
+			case compactTagSubjectHTTP, compactTagSubjectTLS:
+				kind = compactTagSubject
+			}
+			if seen[kind] {
+				return nil, "", "", errMalformedCompact
+			}
+			seen[kind] = true
+		}
+
+		switch tag {
+		case compactTagIssuer:
+			claims.Issuer, err = r.string()
+		case compactTagIssuerAlias:
+			alias, err = r.string()
+		case compactTagSubject:
+			claims.Subject, err = r.string()
+		case compactTagSubjectHTTP:
+			claims.Subject, err = r.string()
+			claims.Subject = "http://" + claims.Subject
+		case compactTagSubjectTLS:
+			claims.Subject, err = r.string()
+			claims.Subject = "https://" + claims.Subject
+		case compactTagExpiresAt:
+			claims.ExpiresAt, err = r.time()
+		case compactTagNotBefore:
+			claims.NotBefore, err = r.time()
+		case compactTagIssuedAt:
+			claims.IssuedAt, err = r.time()
+		case compactTagAudience:
+			var aud string
+			aud, err = r.string()
+			claims.Audience = append(claims.Audience, aud)
+		case compactTagID:
+			claims.ID, err = r.string()
+		case compactTagContentType:
+			var ctype string
+			ctype, err = r.string()
+			claims.ContentType = append(claims.ContentType, ctype)
+		case compactTagRefererHost:
+			var host string
+			host, err = r.string()
+			claims.Referer = referer(claims.Referer)
+			claims.Referer.Hosts = append(claims.Referer.Hosts, host)
+		case compactTagReferer:
+			claims.Referer = referer(claims.Referer)
+		case compactTagRefererEmpty:
+			claims.Referer = referer(claims.Referer)
+			claims.Referer.AllowEmpty = true
+		default:
+			err = errMalformedCompact
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+	if !seen[compactTagIssuer] || !seen[compactTagSubject] {
+		return nil, "", "", errMalformedCompact
+	}
+	return claims, alg, alias, nil
+}
+
+func referer(policy *RefererPolicy) *RefererPolicy {
+	if policy == nil {
+		policy = &RefererPolicy{}
+	}
+	return policy
+}
+
+// decodeCompactToken verifies and decodes a token in the compact profile.
+// aliases maps issuer aliases to issuers.
+func decodeCompactToken(ctx context.Context, store Store, tokenString string, checkAlg func(iss, alg string) bool, aliases map[string]string) (*Claims, error) {
+	payload, sig, _ := strings.Cut(tokenString, ".")
+	b, err := jwt.DecodeSegment(payload)
+	if err != nil {
+		return nil, errMalformedCompact
+	}
+	claims, alg, alias, err := parseCompact(b)
+	if err != nil {
+		return nil, fmt.Errorf("chame: failed to decode compact token: %w", err)
+	}
+	if alias != "" {
+		iss, ok := aliases[alias]
+		if !ok {
+			return nil, &tokenError{ErrUnknownKey, fmt.Errorf("chame: unknown issuer alias %q", alias)}
+		}
+		claims.Issuer = iss
+	}
+	claims.Algorithm = alg
+
+	if checkAlg != nil && !checkAlg(claims.Issuer, alg) {
+		return nil, &tokenError{ErrUnsupportedAlgorithm, fmt.Errorf("chame: algorithm %q is not accepted", alg)}
+	}
+	if err := verifySignature(ctx, store, claims, payload, sig); err != nil {
+		return nil, fmt.Errorf("chame: failed to decode compact token: %w", err)
+	}
+
+	// NOTE(yosida95): Raw is filled in the same form as JWT claims.
+	if raw, err := json.Marshal(claims); err == nil {
+		json.Unmarshal(raw, &claims.Raw)
+	}
+	if err := validateClaims(&claims.Token, time.Now()); err != nil {
+		return nil, fmt.Errorf("chame: failed to decode compact token: %w", classifyJWTError(err))
+	}
+	return claims, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestCompactToken(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		store Store
+		opts  SignOption
+	}{
+		{store: store, opts: SignOption{JwtKid: defaultKid}},
+		{store: store, opts: SignOption{JwtKid: defaultKid, Algorithm: "HS512", IssuerAlias: "y"}},
+		{
+			store: store,
+			opts: SignOption{
+				JwtKid:      defaultKid,
+				NotBefore:   time.Now().Add(-time.Minute),
+				NotAfter:    time.Now().Add(time.Hour),
+				JwtID:       "abc",
+				Audience:    []string{"a.example.com", "b.example.com"},
+				ContentType: []string{"image/*"},
+			},
+		},
+		{store: &keyPairStore{signing: edKey, verifying: edKey.Public()}},
+	} {
+		client, err := NewClient("https://chame.yosida95.com", defaultIss, c.store)
+		if err != nil {
+			t.Fatalf("failed to get new Client: %v", err)
+		}
+		c.opts.Compact = true
+		for _, origin := range []string{"https://example.com/foo.png", "http://example.com/foo.png", "//example.com/foo.png"} {
+			signed, err := client.Sign(context.Background(), origin, c.opts)
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %v", c.opts, err)
+				continue
+			}
+			token := strings.TrimPrefix(signed, client.BaseURL()+proxyPrefix)
+			if !isCompactToken(token) {
+				t.Errorf("%+v: not compact: %q", c.opts, token)
+				continue
+			}
+			jwtOpts := c.opts
+			jwtOpts.Compact = false
+			jwtSigned, _ := client.Sign(context.Background(), origin, jwtOpts)
+			if len(signed) >= len(jwtSigned) {
+				t.Errorf("%+v: compact token is not shorter: %q", c.opts, signed)
+			}
+
+			claims, err := decodeToken(context.Background(), c.store, token, &decodeOptions{
+				issuerAliases: map[string]string{"y": defaultIss},
+			})
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %v", c.opts, err)
+				continue
+			}
+			if claims.Issuer != defaultIss || claims.Subject != origin || claims.KeyID != c.opts.JwtKid || claims.ID != c.opts.JwtID {
+				t.Errorf("%+v: unexpected claims: %+v", c.opts, claims)
+			}
+			if fmt.Sprint(claims.Audience) != fmt.Sprint(c.opts.Audience) || fmt.Sprint(claims.ContentType) != fmt.Sprint(c.opts.ContentType) {
+				t.Errorf("%+v: unexpected claims: %+v", c.opts, claims)
+			}
+			if !c.opts.NotAfter.IsZero() && claims.ExpiresAt.Unix() != c.opts.NotAfter.Unix() {
+				t.Errorf("%+v: unexpected exp: %v", c.opts, claims.ExpiresAt)
+			}
+
+			// tampering with the payload invalidates the signature
+			payload, sig, _ := strings.Cut(token, ".")
+			b, _ := jwt.DecodeSegment(payload)
+			b[len(b)-1] ^= 1
+			tampered := jwt.EncodeSegment(b) + "." + sig
+			if _, err := decodeToken(context.Background(), c.store, tampered, &decodeOptions{
+				issuerAliases: map[string]string{"y": defaultIss},
+			}); !errors.Is(err, ErrBadSignature) && !errors.Is(err, ErrTokenMalformed) {
+				t.Errorf("%+v: expected bad signature, have %v", c.opts, err)
+			}
+		}
+	}
+}
+
+func TestCompactToken_Errors(t *testing.T) {
+	client, err := NewClient("https://chame.yosida95.com", defaultIss, store)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	sign := func(opts SignOption) string {
+		opts.JwtKid, opts.Compact = defaultKid, true
+		signed, err := client.Sign(context.Background(), "https://example.com/foo.png", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return strings.TrimPrefix(signed, client.BaseURL()+proxyPrefix)
+	}
+	for i, c := range []struct {
+		token string
+		err   error
+	}{
+		{token: "AQE.sig", err: ErrTokenMalformed},
+		{token: jwt.EncodeSegment([]byte{2, 1, 0}) + ".sig", err: ErrTokenMalformed},
+		{token: jwt.EncodeSegment([]byte{1, 99, 0}) + ".sig", err: ErrUnsupportedAlgorithm},
+		{token: sign(SignOption{IssuerAlias: "unknown"}), err: ErrUnknownKey},
+		{token: sign(SignOption{NotAfter: time.Now().Add(-time.Hour)}), err: ErrTokenExpired},
+	} {
+		if _, err := decodeToken(context.Background(), store, c.token, nil); !errors.Is(err, c.err) {
+			t.Errorf("%d: expected %v, have %v", i, c.err, err)
+		}
+	}
+
+	if _, err := client.Sign(context.Background(), "https://example.com/foo.png", SignOption{
+		JwtKid:     defaultKid,
+		Compact:    true,
+		Encryption: EncryptDirect,
+	}); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}
+
+func TestChame_ServeProxyCompact(t *testing.T) {
+	client, err := NewClient("https://chame.example.net", "https://chame.example.net", keyStore)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintln(w, req.URL.String())
+		}),
+		Store:            keyStore,
+		ExtraContentType: []string{"text/plain"},
+		IssuerAliases:    map[string]string{"n": "https://chame.example.net"},
+	}
+	signed, err := client.Sign(context.Background(), "https://example.net/cat.jpeg", SignOption{
+		Compact:     true,
+		IssuerAlias: "n",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(signed, client.BaseURL()), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://example.net/cat.jpeg") {
+		t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+}
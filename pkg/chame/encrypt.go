@@ -0,0 +1,163 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Encryption specifies how tokens are encrypted to hide the origin URL from
+// viewers.
+type Encryption int
+
+const (
+	// EncryptNone leaves tokens signed but not encrypted.
+	EncryptNone Encryption = iota
+	// EncryptDirect encrypts tokens with A256GCM using a 32-byte []byte key
+	// as the content encryption key ("dir").
+	EncryptDirect
+	// EncryptKeyWrap encrypts tokens with A256GCM using a random content
+	// encryption key, which is wrapped with A128KW, A192KW or A256KW for a
+	// []byte key of 16, 24 or 32 bytes, RSA-OAEP-256 for *rsa.PublicKey, or
+	// ECDH-ES+A256KW for *ecdsa.PublicKey.
+	EncryptKeyWrap
+)
+
+var (
+	jweKeyAlgorithms = []jose.KeyAlgorithm{
+		jose.DIRECT,
+		jose.A128KW,
+		jose.A192KW,
+		jose.A256KW,
+		jose.RSA_OAEP_256,
+		jose.ECDH_ES_A256KW,
+	}
+	jweContentEncryption = []jose.ContentEncryption{jose.A256GCM}
+)
+
+// jweIssuerHeader is the JWE header parameter that replicates the "iss" claim
+// to look up a decrypting key.
+const jweIssuerHeader jose.HeaderKey = "iss"
+
+// EncodeEncryptedToken signs token like EncodeToken and encrypts the result
+// into a JWE, so that the claims are not readable without the decrypting key.
+// store must implement EncryptingStore.
+func EncodeEncryptedToken(ctx context.Context, store Store, token *Token, kid string, enc Encryption) (string, error) {
+	return encodeEncryptedClaims(ctx, store, &Claims{Token: *token}, kid, "", enc)
+}
+
+func encodeEncryptedClaims(ctx context.Context, store Store, token *Claims, kid string, alg string, enc Encryption) (string, error) {
+	signer, err := newTokenSigner(ctx, store, token.Issuer, kid, alg, enc)
+	if err != nil {
+		return "", err
+	}
+	return signer.sign(token)
+}
+
+// newEncrypter returns a JWE encrypter with the encrypting key identified by
+// iss and kid.
+func newEncrypter(store Store, iss string, kid string, enc Encryption) (jose.Encrypter, error) {
+	estore, ok := store.(EncryptingStore)
+	if !ok {
+		return nil, errors.New("chame: store does not support encryption")
+	}
+	key, err := estore.GetEncryptingKey(iss, kid)
+	if err != nil {
+		return nil, fmt.Errorf("chame: failed to retrieve an encrypting key: %w", err)
+	}
+
+	var keyAlg jose.KeyAlgorithm
+	switch key := key.(type) {
+	case []byte:
+		switch {
+		case enc == EncryptDirect && len(key) == 32:
+			keyAlg = jose.DIRECT
+		case enc == EncryptKeyWrap && len(key) == 16:
+			keyAlg = jose.A128KW
+		case enc == EncryptKeyWrap && len(key) == 24:
+			keyAlg = jose.A192KW
+		case enc == EncryptKeyWrap && len(key) == 32:
+			keyAlg = jose.A256KW
+		default:
+			return nil, errors.New("chame: invalid size of encrypting key")
+		}
+	case *rsa.PublicKey:
+		keyAlg = jose.RSA_OAEP_256
+	case *ecdsa.PublicKey:
+		keyAlg = jose.ECDH_ES_A256KW
+	default:
+		return nil, errors.New("chame: unsupported encrypting key algorithm")
+	}
+	if keyAlg != jose.DIRECT && enc != EncryptKeyWrap {
+		return nil, errors.New("chame: unsupported encryption for the key")
+	}
+
+	opts := (&jose.EncrypterOptions{}).
+		WithContentType("JWT").
+		WithHeader(jweIssuerHeader, iss)
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{
+		Algorithm: keyAlg,
+		Key:       key,
+		KeyID:     kid,
+	}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("chame: failed to encrypt a token: %w", err)
+	}
+	return encrypter, nil
+}
+
+// isEncryptedToken reports whether tokenString is in the JWE compact
+// serialization, which consists of five parts unlike JWS of three.
+func isEncryptedToken(tokenString string) bool {
+	return strings.Count(tokenString, ".") == 4
+}
+
+// decryptToken decrypts a JWE and returns the signed token in it along with
+// the issuer in the JWE header.
+func decryptToken(ctx context.Context, store Store, tokenString string) (string, string, error) {
+	estore, ok := store.(EncryptingStore)
+	if !ok {
+		return "", "", &tokenError{ErrUnsupportedAlgorithm, errors.New("chame: store does not support encryption")}
+	}
+	obj, err := jose.ParseEncryptedCompact(tokenString, jweKeyAlgorithms, jweContentEncryption)
+	if err != nil {
+		return "", "", &tokenError{ErrTokenMalformed, fmt.Errorf("chame: failed to decode encrypted token: %w", err)}
+	}
+	iss, _ := obj.Header.ExtraHeaders[jweIssuerHeader].(string)
+	if cty, _ := obj.Header.ExtraHeaders[jose.HeaderContentType].(string); !strings.EqualFold(cty, "JWT") {
+		return "", "", &tokenError{ErrTokenMalformed, errors.New("chame: failed to decode encrypted token: unexpected content type")}
+	}
+	var key interface{}
+	if sc, ok := store.(DecryptingStoreContext); ok {
+		key, err = sc.GetDecryptingKeyContext(ctx, iss, obj.Header.KeyID)
+	} else {
+		key, err = estore.GetDecryptingKey(iss, obj.Header.KeyID)
+	}
+	if err != nil {
+		return "", "", keyLookupError(fmt.Errorf("chame: failed to retrieve a decrypting key: %w", err))
+	}
+	signed, err := obj.Decrypt(key)
+	if err != nil {
+		return "", "", &tokenError{ErrBadSignature, fmt.Errorf("chame: failed to decrypt a token: %w", err)}
+	}
+	return string(signed), iss, nil
+}
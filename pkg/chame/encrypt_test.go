@@ -0,0 +1,182 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type encstore struct {
+	*mockstore
+	key interface{}
+}
+
+func (store *encstore) GetEncryptingKey(iss string, kid string) (interface{}, error) {
+	key, err := store.GetDecryptingKey(iss, kid)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey, nil
+	}
+	return key, nil
+}
+
+func (store *encstore) GetDecryptingKey(iss string, kid string) (interface{}, error) {
+	if iss == store.iss && kid == store.kid {
+		return store.key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func TestEncryptedToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const origin = "https://example.com/private/cat.png"
+	for i, c := range []struct {
+		key interface{}
+		enc Encryption
+		err bool
+	}{
+		{key: []byte(strings.Repeat("k", 32)), enc: EncryptDirect},
+		{key: []byte(strings.Repeat("k", 16)), enc: EncryptKeyWrap},
+		{key: []byte(strings.Repeat("k", 32)), enc: EncryptKeyWrap},
+		{key: rsaKey, enc: EncryptKeyWrap},
+		{key: ecKey, enc: EncryptKeyWrap},
+		{key: []byte(strings.Repeat("k", 16)), enc: EncryptDirect, err: true},
+		{key: rsaKey, enc: EncryptDirect, err: true},
+	} {
+		estore := &encstore{mockstore: store, key: c.key}
+		client, err := NewClient("https://chame.yosida95.com", defaultIss, estore)
+		if err != nil {
+			t.Fatalf("failed to get new Client: %v", err)
+		}
+		signed, err := client.Sign(context.Background(), origin, SignOption{
+			JwtKid:     defaultKid,
+			Encryption: c.enc,
+		})
+		if c.err {
+			if err == nil {
+				t.Errorf("%d: expected error not occurred", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		token := strings.TrimPrefix(signed, client.BaseURL()+proxyPrefix)
+		if !isEncryptedToken(token) {
+			t.Errorf("%d: not encrypted: %q", i, token)
+			continue
+		}
+
+		chame := &Chame{
+			Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+				w.Header().Set("Content-Type", "text/plain")
+				fmt.Fprint(w, req.URL.String())
+			}),
+			Store:            estore,
+			ExtraContentType: []string{"text/plain"},
+		}
+		w := httptest.NewRecorder()
+		chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, proxyPrefix+token, nil))
+		if w.Code != http.StatusOK || w.Body.String() != origin {
+			t.Errorf("%d: unexpected response: %d %q", i, w.Code, w.Body.String())
+		}
+
+		// tamper with the ciphertext
+		parts := strings.Split(token, ".")
+		parts[3] = strings.Repeat("A", len(parts[3]))
+		if _, err := DecodeToken(context.Background(), estore, strings.Join(parts, ".")); err == nil {
+			t.Errorf("%d: tampered token decoded", i)
+		}
+	}
+}
+
+type ctxEncstore struct {
+	*encstore
+	err  error
+	seen interface{}
+}
+
+type ctxEncstoreKey struct{}
+
+func (store *ctxEncstore) GetDecryptingKeyContext(ctx context.Context, iss string, kid string) (interface{}, error) {
+	store.seen = ctx.Value(ctxEncstoreKey{})
+	if store.err != nil {
+		return nil, store.err
+	}
+	return store.GetDecryptingKey(iss, kid)
+}
+
+func TestDecryptToken_keyLookup(t *testing.T) {
+	estore := &encstore{mockstore: store, key: []byte(strings.Repeat("k", 32))}
+	token, err := encodeEncryptedClaims(context.Background(), estore, &Claims{Token: Token{
+		Issuer:  defaultIss,
+		Subject: "https://example.com/cat.png",
+	}}, defaultKid, "", EncryptDirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxEncstoreKey{}, "value")
+	for _, c := range []struct {
+		err  error
+		code int
+	}{
+		{code: http.StatusOK},
+		{err: ErrKeyNotFound, code: http.StatusNotFound},
+		{err: errors.New("backend is down"), code: http.StatusServiceUnavailable},
+	} {
+		sc := &ctxEncstore{encstore: estore, err: c.err}
+		chame := &Chame{
+			Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+				w.Header().Set("Content-Type", "text/plain")
+			}),
+			Store:            sc,
+			ExtraContentType: []string{"text/plain"},
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, proxyPrefix+token, nil)
+		chame.ServeHTTP(w, req.WithContext(ctx))
+		if w.Code != c.code {
+			t.Errorf("%v: expect %d, got %d", c.err, c.code, w.Code)
+		}
+		if sc.seen != "value" {
+			t.Errorf("%v: context is not passed to GetDecryptingKeyContext", c.err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Errors returned by DecodeToken and DecodeClaims. They can be tested with
+// errors.Is regardless of the underlying JWT implementation.
+var (
+	// ErrTokenMalformed means the token cannot be parsed.
+	ErrTokenMalformed = errors.New("chame: token is malformed")
+	// ErrTokenExpired means the token has expired.
+	ErrTokenExpired = errors.New("chame: token is expired")
+	// ErrTokenNotYetValid means the token is not valid yet, or is issued in
+	// the future.
+	ErrTokenNotYetValid = errors.New("chame: token is not valid yet")
+	// ErrUnknownKey means the Store has no key for the issuer and key ID of
+	// the token.
+	ErrUnknownKey = errors.New("chame: unknown key")
+	// ErrBadSignature means the signature of the token, or the
+	// authentication tag of the encrypted token, is invalid.
+	ErrBadSignature = errors.New("chame: signature is invalid")
+	// ErrUnsupportedAlgorithm means the token is signed or encrypted with an
+	// algorithm that is not supported or not accepted.
+	ErrUnsupportedAlgorithm = errors.New("chame: algorithm is not supported")
+	// ErrTokenAudience means the "aud" claim of the token does not contain
+	// the expected audience.
+	ErrTokenAudience = errors.New("chame: token is not intended for the audience")
+)
+
+// tokenError classifies err as kind while keeping the message of err.
+type tokenError struct {
+	kind error
+	err  error
+}
+
+func (e *tokenError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tokenError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// isTokenError reports whether err is already classified.
+func isTokenError(err error) bool {
+	var tokenErr *tokenError
+	return errors.As(err, &tokenErr)
+}
+
+// classifyJWTError classifies an error returned by the JWT parser.
+func classifyJWTError(err error) error {
+	if err == nil || isTokenError(err) {
+		return err
+	}
+	var jwtErr *jwt.ValidationError
+	if !errors.As(err, &jwtErr) {
+		return err
+	}
+	var kind error
+	switch {
+	case jwtErr.Errors&jwt.ValidationErrorMalformed != 0:
+		kind = ErrTokenMalformed
+	case jwtErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		// NOTE(yosida95): errors from the key function are classified
+		// there, and the rest is an unknown algorithm.
+		kind = ErrUnsupportedAlgorithm
+	case jwtErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		// NOTE(yosida95): the parser reports an algorithm not in the list
+		// of valid methods as an invalid signature without the cause.
+		if jwtErr.Inner == nil {
+			kind = ErrUnsupportedAlgorithm
+		} else {
+			kind = ErrBadSignature
+		}
+	case jwtErr.Errors&jwt.ValidationErrorExpired != 0:
+		kind = ErrTokenExpired
+	case jwtErr.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		kind = ErrTokenNotYetValid
+	case jwtErr.Errors&jwt.ValidationErrorAudience != 0:
+		kind = ErrTokenAudience
+	default:
+		return err
+	}
+	return &tokenError{kind: kind, err: err}
+}
@@ -0,0 +1,140 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxFilterBodySize is the maximum size of a response body to be held in
+// memory to apply bodyFilters.
+const maxFilterBodySize = 32 << 20
+
+// bodyFilter rewrites a response body from the origin before it is sent to
+// the client. apply may modify the response header, and a non-nil error
+// replaces the response with 502 Bad Gateway. A filter that is not required
+// is skipped when the body is larger than maxFilterBodySize.
+//
+// A filter may set stream in place of apply to process the body as it
+// arrives without holding it in memory. stream copies the body from src to
+// dst, and its error replaces the response with 502 Bad Gateway if nothing
+// has been written to dst yet, or aborts the response otherwise. Data left
+// in src after stream returns is discarded.
+type bodyFilter struct {
+	required bool
+	apply    func(hdr http.Header, body []byte) ([]byte, error)
+	stream   func(dst io.Writer, src io.Reader) error
+	// inspectOnly is set if stream never modifies the body.
+	inspectOnly bool
+}
+
+// run applies f to body held in memory.
+func (f bodyFilter) run(hdr http.Header, body []byte) ([]byte, error) {
+	if f.stream == nil {
+		return f.apply(hdr, body)
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(body))
+	if err := f.stream(&buf, bytes.NewReader(body)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// canStream reports whether all of filters can process a body as a stream.
+func canStream(filters []bodyFilter) bool {
+	for _, f := range filters {
+		if f.stream == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// pipeFilters starts a goroutine that copies a body written to the returned
+// writer into dst through stream of filters in order. Closing the writer
+// signals the end of the body, and then the result is sent to the returned
+// channel.
+func pipeFilters(dst io.Writer, filters []bodyFilter) (*io.PipeWriter, <-chan error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		if len(filters) == 1 {
+			err = filters[0].stream(dst, pr)
+		} else {
+			next, wait := pipeFilters(dst, filters[1:])
+			err = filters[0].stream(next, pr)
+			next.CloseWithError(err)
+			if nextErr := <-wait; err == nil {
+				err = nextErr
+			}
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, pr)
+		}
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return pw, done
+}
+
+// bodyFilters returns filters to be applied to a response of ctype. hdr is
+// the response header and reqHdr is the header of the request from the
+// client. allow reports whether a Content-Type may be served for the URL.
+func (chame *Chame) bodyFilters(hdr http.Header, ctype string, reqHdr http.Header, allow func(string) bool) []bodyFilter {
+	var filters []bodyFilter
+	if f, ok := chame.ImageLimits.filter(ctype); ok {
+		filters = append(filters, f)
+	}
+	if chame.SanitizeSVG && ctype == "image/svg+xml" {
+		filters = append(filters, bodyFilter{
+			required: true,
+			apply: func(_ http.Header, body []byte) ([]byte, error) {
+				return sanitizeSVG(body)
+			},
+		})
+	}
+	if f, ok := chame.stripMetadataFilter(ctype); ok {
+		filters = append(filters, f)
+	}
+	if f, ok := chame.transcodeFilter(hdr, ctype, reqHdr, allow); ok {
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+// requiresFilter reports whether any of filters is required.
+func requiresFilter(filters []bodyFilter) bool {
+	for _, f := range filters {
+		if f.required {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBody reports whether a response of code may have a body.
+func hasBody(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+func isIdentityEncoding(hdr http.Header) bool {
+	enc := hdr.Get("Content-Encoding")
+	return enc == "" || strings.EqualFold(enc, "identity")
+}
@@ -0,0 +1,243 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// ErrImageTooLarge is returned when a proxied image exceeds ImageLimits.
+var ErrImageTooLarge = errors.New("chame: image too large")
+
+// ImageLimits restricts images of PNG, GIF, JPEG, BMP, TIFF and WebP formats.
+// Zero value of each field means no limit.
+type ImageLimits struct {
+	// MaxWidth and MaxHeight limit the width and height of the canvas.
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixels limits the product of the width and height of the canvas.
+	MaxPixels int64
+	// MaxFrames limits the number of frames of animated GIF, PNG and WebP
+	// images.
+	MaxFrames int
+}
+
+// maxImageHeaderSize is the maximum size of the beginning of an image held to
+// check it against ImageLimits before sending it to the client.
+const maxImageHeaderSize = 1 << 20
+
+// imageFrameCounter is a function that counts frames of an image, keyed by
+// Content-Type. It may stop counting once the count exceeds limit. A nil
+// function means the format is never animated.
+var imageFrameCounter = map[string]func(r *bufio.Reader, limit int) (int, error){
+	"image/bmp":  nil,
+	"image/gif":  countGIFFrames,
+	"image/jpeg": nil,
+	"image/jpg":  nil,
+	"image/png":  countPNGFrames,
+	"image/tiff": nil,
+	"image/webp": countWebPFrames,
+}
+
+func (l *ImageLimits) filter(ctype string) (bodyFilter, bool) {
+	if l == nil {
+		return bodyFilter{}, false
+	}
+	count, ok := imageFrameCounter[ctype]
+	if !ok {
+		return bodyFilter{}, false
+	}
+	return bodyFilter{
+		required:    true,
+		inspectOnly: true,
+		stream: func(dst io.Writer, src io.Reader) error {
+			return l.check(dst, src, count)
+		},
+	}, true
+}
+
+// check copies an image from src to dst, checking its header before any of
+// it is written and counting frames while it is copied.
+func (l *ImageLimits) check(dst io.Writer, src io.Reader, count func(*bufio.Reader, int) (int, error)) error {
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(io.LimitReader(src, maxImageHeaderSize), &head))
+	if err != nil {
+		return fmt.Errorf("chame: failed to decode image header: %w", err)
+	}
+	switch {
+	case l.MaxWidth > 0 && cfg.Width > l.MaxWidth,
+		l.MaxHeight > 0 && cfg.Height > l.MaxHeight,
+		l.MaxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > l.MaxPixels:
+		return fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	body := io.MultiReader(&head, src)
+	if l.MaxFrames > 0 && count != nil {
+		// NOTE(yosida95): the output is held while counting frames as
+		// long as it fits in the buffer, so that an image exceeding the
+		// limit is rejected with an error response rather than aborted.
+		held := bufio.NewWriterSize(dst, maxImageHeaderSize)
+		n, err := count(bufio.NewReader(io.TeeReader(body, held)), l.MaxFrames)
+		if err != nil {
+			return err
+		}
+		if n > l.MaxFrames {
+			return fmt.Errorf("%w: %d frames", ErrImageTooLarge, n)
+		}
+		if err := held.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(dst, body)
+	return err
+}
+
+var errMalformedGIF = errors.New("chame: malformed GIF")
+
+// countGIFFrames counts image descriptors in a GIF image without decoding
+// them.
+func countGIFFrames(r *bufio.Reader, limit int) (int, error) {
+	var hdr [13]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, errMalformedGIF
+	}
+	discard := func(n int) error {
+		if _, err := r.Discard(n); err != nil {
+			return errMalformedGIF
+		}
+		return nil
+	}
+	if flags := hdr[10]; flags&0x80 != 0 {
+		if err := discard(3 << (flags&0x07 + 1)); err != nil {
+			return 0, err
+		}
+	}
+	// skipSubBlocks skips data sub-blocks up to the block terminator.
+	skipSubBlocks := func() error {
+		for {
+			n, err := r.ReadByte()
+			if err != nil {
+				return errMalformedGIF
+			}
+			if n == 0 {
+				return nil
+			}
+			if err := discard(int(n)); err != nil {
+				return err
+			}
+		}
+	}
+
+	frames := 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errMalformedGIF
+		}
+		switch b {
+		case 0x21: // extension
+			if err = discard(1); err == nil {
+				err = skipSubBlocks()
+			}
+		case 0x2c: // image descriptor
+			var desc [9]byte
+			if _, err := io.ReadFull(r, desc[:]); err != nil {
+				return 0, errMalformedGIF
+			}
+			frames++
+			if frames > limit {
+				return frames, nil
+			}
+			if flags := desc[8]; flags&0x80 != 0 {
+				err = discard(3 << (flags&0x07 + 1))
+			}
+			// skip the LZW minimum code size and image data
+			if err == nil {
+				if err = discard(1); err == nil {
+					err = skipSubBlocks()
+				}
+			}
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return 0, errMalformedGIF
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// countPNGFrames returns the number of frames declared in the acTL chunk of
+// an animated PNG, or 1 for a static one.
+func countPNGFrames(r *bufio.Reader, _ int) (int, error) {
+	var sig [8]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return 0, errMalformedPNG
+	}
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return 0, errMalformedPNG
+		}
+		n := binary.BigEndian.Uint32(hdr[:4])
+		switch string(hdr[4:]) {
+		case "acTL":
+			var num [4]byte
+			if _, err := io.ReadFull(r, num[:]); n < 4 || err != nil {
+				return 0, errMalformedPNG
+			}
+			return int(binary.BigEndian.Uint32(num[:])), nil
+		case "IDAT":
+			return 1, nil
+		}
+		// data and CRC
+		if n > 1<<31-1 {
+			return 0, errMalformedPNG
+		}
+		if _, err := r.Discard(int(n) + 4); err != nil {
+			return 0, errMalformedPNG
+		}
+	}
+}
+
+// countWebPFrames counts ANMF chunks in an animated WebP image, or returns 1
+// for a static one.
+func countWebPFrames(r *bufio.Reader, limit int) (int, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil || string(hdr[:4]) != "RIFF" || string(hdr[8:]) != "WEBP" {
+		return 0, errMalformedWebP
+	}
+	frames := 0
+	for frames <= limit {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			break
+		}
+		n := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if string(chunk[:4]) == "ANMF" {
+			frames++
+		}
+		if _, err := io.CopyN(io.Discard, r, n+n&1); err != nil {
+			break
+		}
+	}
+	return max(frames, 1), nil
+}
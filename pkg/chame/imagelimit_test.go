@@ -0,0 +1,151 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestImageLimits(t *testing.T) {
+	// a PNG image that declares 50000x50000 pixels
+	ihdr := binary.BigEndian.AppendUint32(nil, 50000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 50000)
+	ihdr = append(ihdr, 8, 0, 0, 0, 0)
+	bomb := []byte("\x89PNG\r\n\x1a\n")
+	bomb = append(bomb, pngChunk("IHDR", ihdr)...)
+	bomb = append(bomb, pngChunk("IDAT", nil)...)
+	bomb = append(bomb, pngChunk("IEND", nil)...)
+
+	frame := image.NewPaletted(image.Rect(0, 0, 16, 16), palette.Plan9)
+	var animated bytes.Buffer
+	if err := gif.EncodeAll(&animated, &gif.GIF{
+		Image: []*image.Paletted{frame, frame, frame},
+		Delay: []int{0, 0, 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		limits ImageLimits
+		ctype  string
+		body   []byte
+		err    error
+	}{
+		{
+			limits: ImageLimits{MaxPixels: 1 << 24},
+			ctype:  "image/png",
+			body:   bomb,
+			err:    ErrImageTooLarge,
+		},
+		{
+			limits: ImageLimits{MaxWidth: 50000, MaxHeight: 50000},
+			ctype:  "image/png",
+			body:   bomb,
+		},
+		{
+			limits: ImageLimits{MaxFrames: 2},
+			ctype:  "image/gif",
+			body:   animated.Bytes(),
+			err:    ErrImageTooLarge,
+		},
+		{
+			limits: ImageLimits{MaxWidth: 16, MaxFrames: 3},
+			ctype:  "image/gif",
+			body:   animated.Bytes(),
+		},
+	} {
+		f, ok := c.limits.filter(c.ctype)
+		if !ok {
+			t.Errorf("%q: filter not found", c.ctype)
+			continue
+		}
+		_, err := f.run(http.Header{}, c.body)
+		if !errors.Is(err, c.err) {
+			t.Errorf("expect %v, got %v", c.err, err)
+		}
+	}
+}
+
+func TestChame_ImageLimits(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 16, 16), palette.Plan9)
+	var animated bytes.Buffer
+	if err := gif.EncodeAll(&animated, &gif.GIF{
+		Image: []*image.Paletted{frame, frame},
+		Delay: []int{0, 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "image/gif")
+			w.Header().Set("Cache-Control", "max-age=86400")
+			w.Write(animated.Bytes())
+		}),
+		Store:       keyStore,
+		ImageLimits: &ImageLimits{MaxFrames: 1},
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.gif"), nil)
+	chame.ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expect %d, got %d", http.StatusBadGateway, w.Code)
+	}
+	if have := w.Header().Get("Cache-Control"); have != "" {
+		t.Errorf("Cache-Control must be removed, got %q", have)
+	}
+}
+
+func TestChame_ImageLimitsLargeBody(t *testing.T) {
+	ihdr := binary.BigEndian.AppendUint32(nil, 16)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 16)
+	ihdr = append(ihdr, 8, 0, 0, 0, 0)
+	body := []byte("\x89PNG\r\n\x1a\n")
+	body = append(body, pngChunk("IHDR", ihdr)...)
+	// an image larger than maxFilterBodySize is checked without holding it
+	body = append(body, pngChunk("IDAT", make([]byte, maxFilterBodySize+1))...)
+	body = append(body, pngChunk("IEND", nil)...)
+
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			io.CopyBuffer(w, bytes.NewReader(body), make([]byte, 32<<10))
+		}),
+		Store:       keyStore,
+		ImageLimits: &ImageLimits{MaxWidth: 16, MaxFrames: 1},
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.png"), nil)
+	chame.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect %d, got %d", http.StatusOK, w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), body) {
+		t.Errorf("body must be passed through")
+	}
+	if have := w.Header().Get("Content-Length"); have != strconv.Itoa(len(body)) {
+		t.Errorf("Content-Length must be retained, got %q", have)
+	}
+}
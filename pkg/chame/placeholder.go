@@ -0,0 +1,111 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FailureClass classifies failures to proxy an image.
+type FailureClass int
+
+const (
+	// FailureUnavailable means the origin could not be reached or responded
+	// with a server error.
+	FailureUnavailable FailureClass = iota
+	// FailureNotFound means the origin responded with a client error such
+	// as 404 Not Found.
+	FailureNotFound
+	// FailureRejected means chame rejected the content from the origin, for
+	// example, because of its Content-Type.
+	FailureRejected
+)
+
+func (c FailureClass) String() string {
+	switch c {
+	case FailureUnavailable:
+		return "unavailable"
+	case FailureNotFound:
+		return "not-found"
+	case FailureRejected:
+		return "rejected"
+	}
+	return "FailureClass(" + strconv.Itoa(int(c)) + ")"
+}
+
+func classifyStatus(code int) FailureClass {
+	if code < 500 {
+		return FailureNotFound
+	}
+	return FailureUnavailable
+}
+
+// DefaultPlaceholderMaxAge is the cache lifetime of placeholder images whose
+// MaxAge is zero.
+const DefaultPlaceholderMaxAge = 1 * time.Minute
+
+// Placeholder is an image served in place of an error response.
+type Placeholder struct {
+	ContentType string
+	Body        []byte
+	// MaxAge is the cache lifetime of the placeholder. If zero,
+	// DefaultPlaceholderMaxAge is used. Placeholders for URLs restricted by
+	// a RefererPolicy are cached privately.
+	MaxAge time.Duration
+}
+
+// Placeholders is a set of placeholder images by FailureClass.
+type Placeholders map[FailureClass]*Placeholder
+
+// placeholderFailureHeader is the response header that records the reason
+// why a placeholder is served.
+const placeholderFailureHeader = "X-Chame-Failure"
+
+func (chame *Chame) lookupPlaceholder(iss string, class FailureClass) *Placeholder {
+	if p, ok := chame.IssuerPlaceholders[iss][class]; ok {
+		return p
+	}
+	return chame.Placeholders[class]
+}
+
+// serve writes p as a successful response. class and code describe the
+// original failure, and private keeps shared caches from storing it.
+func (p *Placeholder) serve(w http.ResponseWriter, class FailureClass, code int, private bool) {
+	maxAge := p.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultPlaceholderMaxAge
+	}
+
+	hdr := w.Header()
+	for _, key := range passThroughRespHeaders {
+		hdr.Del(key)
+	}
+	hdr.Set(headerKeyContentType, p.ContentType)
+	hdr.Set("Content-Length", strconv.Itoa(len(p.Body)))
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+	hdr.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int(maxAge.Seconds())))
+	hdr.Set(placeholderFailureHeader, fmt.Sprintf("%s; status=%d", class, code))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(p.Body); err != nil {
+		log.Printf("chame: failed to write a placeholder: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChame_Placeholder(t *testing.T) {
+	notFound := &Placeholder{ContentType: "image/png", Body: []byte("not found")}
+	rejected := &Placeholder{ContentType: "image/png", Body: []byte("rejected")}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			switch req.URL.Path {
+			case "/missing.png":
+				httpError(w, http.StatusNotFound)
+			case "/down.png":
+				httpError(w, http.StatusInternalServerError)
+			case "/page.html":
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html>"))
+			}
+		}),
+		Store: keyStore,
+		Placeholders: Placeholders{
+			FailureNotFound: notFound,
+		},
+		IssuerPlaceholders: map[string]Placeholders{
+			"https://chame.example.net": {
+				FailureRejected: rejected,
+			},
+		},
+	}
+	for _, c := range []struct {
+		url     string
+		code    int
+		body    string
+		failure string
+	}{
+		{
+			url:     "https://example.net/missing.png",
+			code:    http.StatusOK,
+			body:    "not found",
+			failure: "not-found; status=404",
+		},
+		{
+			url:     "https://example.net/page.html",
+			code:    http.StatusOK,
+			body:    "rejected",
+			failure: "rejected; status=502",
+		},
+		{
+			url:  "https://example.net/down.png",
+			code: http.StatusInternalServerError,
+			body: "Internal Server Error\n",
+		},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, signedPath(t, c.url), nil)
+		chame.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("expect %d, got %d", c.code, w.Code)
+		}
+		if have := w.Body.String(); have != c.body {
+			t.Errorf("expect %q, got %q", c.body, have)
+		}
+		if have := w.Header().Get(placeholderFailureHeader); have != c.failure {
+			t.Errorf("expect %q, got %q", c.failure, have)
+		}
+		if c.failure != "" {
+			if have := w.Header().Get("Cache-Control"); have != "public, max-age=60" {
+				t.Errorf("unexpected Cache-Control: %q", have)
+			}
+		}
+	}
+
+	// placeholders for Referer-restricted URLs are not shared
+	chame.RefererPolicies = map[string]*RefererPolicy{
+		"https://chame.example.net": {AllowEmpty: true},
+	}
+	w := httptest.NewRecorder()
+	chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/missing.png"), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "not found" {
+		t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+	if have := w.Header().Get("Cache-Control"); have != "private, max-age=60" {
+		t.Errorf("unexpected Cache-Control: %q", have)
+	}
+}
@@ -30,6 +30,17 @@ type ProxyRequest struct {
 	Context context.Context
 	URL     *url.URL
 	Header  http.Header
+	// Claims is the verified claims of the signed URL.
+	Claims *Claims
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the verified claims of the signed URL being
+// proxied, which ServeProxy attaches to the context of ProxyRequest.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
 }
 
 type HTTPProxy struct {
@@ -0,0 +1,114 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RefererPolicy restricts hosts of pages that may load signed URLs, which are
+// identified by the Origin header, or the Referer header in its absence.
+type RefererPolicy struct {
+	// Hosts is a list of allowed hosts. An entry starting with "*." matches
+	// subdomains of the rest, such as "*.example.com" for
+	// "www.example.com".
+	Hosts []string `json:"hosts,omitempty"`
+	// AllowEmpty allows requests with neither Origin nor Referer, such as
+	// those from privacy-conscious browsers or opened directly.
+	AllowEmpty bool `json:"empty,omitempty"`
+}
+
+// refererHost returns the lowercased host name of the page that made req, or
+// an empty string if unknown. ok is false if the header is present but
+// malformed or opaque.
+func refererHost(req *http.Request) (host string, ok bool) {
+	ref := req.Header.Get("Origin")
+	if ref == "" {
+		ref = req.Header.Get("Referer")
+	}
+	if ref == "" {
+		return "", true
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	host = u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host), true
+}
+
+// allows reports whether policy allows requests from host, where an empty
+// host means the requests have neither Origin nor Referer.
+func (policy *RefererPolicy) allows(host string) bool {
+	if host == "" {
+		return policy.AllowEmpty
+	}
+	for _, pattern := range policy.Hosts {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRefererPolicy reports whether any RefererPolicy applies to claims, in
+// which case responses depend on the page that made the request.
+func (chame *Chame) hasRefererPolicy(claims *Claims) bool {
+	return chame.RefererPolicies[claims.Issuer] != nil || claims.Referer != nil
+}
+
+// privateCacheControl returns the value of Cache-Control that prevents shared
+// caches from storing a response, keeping directives of cc for private ones.
+func privateCacheControl(cc string) string {
+	directives := []string{"private"}
+	for _, d := range strings.Split(cc, ",") {
+		d = strings.TrimSpace(d)
+		name, _, _ := strings.Cut(strings.ToLower(d), "=")
+		switch name {
+		case "", "public", "private", "s-maxage", "proxy-revalidate":
+			continue
+		}
+		directives = append(directives, d)
+	}
+	return strings.Join(directives, ", ")
+}
+
+// checkReferer reports whether req is allowed by the policy of the issuer in
+// RefererPolicies and by the policy in claims.
+func (chame *Chame) checkReferer(req *http.Request, claims *Claims) bool {
+	issuerPolicy := chame.RefererPolicies[claims.Issuer]
+	if issuerPolicy == nil && claims.Referer == nil {
+		return true
+	}
+	host, ok := refererHost(req)
+	if !ok {
+		return false
+	}
+	if issuerPolicy != nil && !issuerPolicy.allows(host) {
+		return false
+	}
+	return claims.Referer == nil || claims.Referer.allows(host)
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChame_Referer(t *testing.T) {
+	const iss = "https://chame.example.net"
+	client, err := NewClient("https://chame.example.net", iss, keyStore)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Cache-Control", "public, max-age=86400, s-maxage=604800")
+			w.Write([]byte("ok"))
+		}),
+		Store:            keyStore,
+		ExtraContentType: []string{"text/plain"},
+	}
+	sign := func(policy *RefererPolicy, compact bool) string {
+		signed, err := client.Sign(context.Background(), "https://example.com/cat.png", SignOption{
+			Referer: policy,
+			Compact: compact,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return strings.TrimPrefix(signed, client.BaseURL())
+	}
+
+	claimPolicy := &RefererPolicy{Hosts: []string{"blog.example.org", "*.example.com"}}
+	for _, c := range []struct {
+		policies map[string]*RefererPolicy
+		claim    *RefererPolicy
+		origin   string
+		referer  string
+		code     int
+	}{
+		{code: http.StatusOK},
+		{claim: claimPolicy, referer: "https://blog.example.org/post/1", code: http.StatusOK},
+		{claim: claimPolicy, referer: "https://BLOG.example.org:8443/", code: http.StatusOK},
+		{claim: claimPolicy, origin: "https://www.example.com", code: http.StatusOK},
+		{claim: claimPolicy, origin: "https://evil.example.net", referer: "https://blog.example.org/", code: http.StatusForbidden},
+		{claim: claimPolicy, referer: "https://example.com/", code: http.StatusForbidden},
+		{claim: claimPolicy, referer: "https://evilexample.com/", code: http.StatusForbidden},
+		{claim: claimPolicy, code: http.StatusForbidden},
+		{claim: &RefererPolicy{AllowEmpty: true}, code: http.StatusOK},
+		{claim: &RefererPolicy{AllowEmpty: true}, origin: "null", code: http.StatusForbidden},
+		{
+			policies: map[string]*RefererPolicy{iss: {Hosts: []string{"*.example.com"}, AllowEmpty: true}},
+			code:     http.StatusOK,
+		},
+		{
+			policies: map[string]*RefererPolicy{iss: {Hosts: []string{"*.example.com"}}},
+			referer:  "https://blog.example.org/",
+			code:     http.StatusForbidden,
+		},
+		{
+			policies: map[string]*RefererPolicy{iss: {Hosts: []string{"*.example.com", "blog.example.org"}}},
+			claim:    &RefererPolicy{Hosts: []string{"www.example.com"}},
+			referer:  "https://blog.example.org/",
+			code:     http.StatusForbidden,
+		},
+		{
+			policies: map[string]*RefererPolicy{"https://other.example.net": {}},
+			referer:  "https://blog.example.org/",
+			code:     http.StatusOK,
+		},
+	} {
+		chame.RefererPolicies = c.policies
+		for _, compact := range []bool{false, true} {
+			req := httptest.NewRequest(http.MethodGet, sign(c.claim, compact), nil)
+			if c.origin != "" {
+				req.Header.Set("Origin", c.origin)
+			}
+			if c.referer != "" {
+				req.Header.Set("Referer", c.referer)
+			}
+			w := httptest.NewRecorder()
+			chame.ServeHTTP(w, req)
+			if w.Code != c.code {
+				t.Errorf("%+v %+v %t: expect %d, got %d", c.policies, c.claim, compact, c.code, w.Code)
+			}
+
+			restricted := c.claim != nil || c.policies[iss] != nil
+			vary := strings.Join(w.Header().Values("Vary"), ", ")
+			if have := vary == "Origin, Referer"; have != restricted {
+				t.Errorf("%+v %+v %t: unexpected Vary: %q", c.policies, c.claim, compact, vary)
+			}
+			if w.Code != http.StatusOK {
+				continue
+			}
+			expect := "public, max-age=86400, s-maxage=604800"
+			if restricted {
+				expect = "private, max-age=86400"
+			}
+			if have := w.Header().Get("Cache-Control"); have != expect {
+				t.Errorf("%+v %+v %t: expect Cache-Control %q, got %q", c.policies, c.claim, compact, expect, have)
+			}
+		}
+	}
+}
+
+func TestPrivateCacheControl(t *testing.T) {
+	for _, c := range []struct {
+		in     string
+		expect string
+	}{
+		{in: "", expect: "private"},
+		{in: "public, max-age=60", expect: "private, max-age=60"},
+		{in: "max-age=60, S-MAXAGE=3600, proxy-revalidate, immutable", expect: "private, max-age=60, immutable"},
+		{in: "private, no-cache", expect: "private, no-cache"},
+		{in: "no-store", expect: "private, no-store"},
+	} {
+		if have := privateCacheControl(c.in); have != c.expect {
+			t.Errorf("%q: expect %q, got %q", c.in, c.expect, have)
+		}
+	}
+}
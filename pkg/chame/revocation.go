@@ -0,0 +1,74 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrTokenRevoked is returned when a token is revoked by RevocationChecker.
+var ErrTokenRevoked = errors.New("chame: token revoked")
+
+// errRevocationUnavailable is returned when RevocationChecker fails.
+var errRevocationUnavailable = errors.New("chame: failed to check revocation")
+
+// RevocationQuery identifies a signed URL to be checked for revocation.
+type RevocationQuery struct {
+	// ID is the "jti" claim, which may be empty.
+	ID string
+	// Subject is the "sub" claim, i.e. the original URL.
+	Subject string
+	// Issuer and KeyID are the "iss" claim and the "kid" header value,
+	// which identify the key that signed the URL.
+	Issuer string
+	KeyID  string
+}
+
+// RevocationChecker reports whether signed URLs are revoked.
+type RevocationChecker interface {
+	// IsRevoked reports whether a URL identified by q is revoked. A non-nil
+	// error means the revocation status is unknown, and the URL is rejected
+	// with 503 Service Unavailable.
+	IsRevoked(ctx context.Context, q *RevocationQuery) (bool, error)
+}
+
+func checkRevocation(ctx context.Context, rc RevocationChecker, claims *Claims) error {
+	revoked, err := rc.IsRevoked(ctx, &RevocationQuery{
+		ID:      claims.ID,
+		Subject: claims.Subject,
+		Issuer:  claims.Issuer,
+		KeyID:   claims.KeyID,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRevocationUnavailable, err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// NewTokenID returns a random value suitable for SignOption.JwtID.
+func NewTokenID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
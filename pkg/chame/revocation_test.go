@@ -0,0 +1,81 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type revocationfunc func(*RevocationQuery) (bool, error)
+
+func (fn revocationfunc) IsRevoked(_ context.Context, q *RevocationQuery) (bool, error) {
+	return fn(q)
+}
+
+func TestChame_Revocation(t *testing.T) {
+	client, err := NewClient("https://chame.yosida95.com", defaultIss, store)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("image"))
+		}),
+		Store: store,
+		Revocation: revocationfunc(func(q *RevocationQuery) (bool, error) {
+			switch {
+			case q.ID == "unavailable":
+				return false, errors.New("backend down")
+			case q.ID == "revoked":
+				return true, nil
+			case q.Subject == "https://example.com/revoked.png":
+				return true, nil
+			case q.Issuer == defaultIss && q.KeyID == "old":
+				return true, nil
+			}
+			return false, nil
+		}),
+	}
+	for _, c := range []struct {
+		url  string
+		jti  string
+		code int
+	}{
+		{url: "https://example.com/cat.png", code: http.StatusOK},
+		{url: "https://example.com/cat.png", jti: NewTokenID(), code: http.StatusOK},
+		{url: "https://example.com/cat.png", jti: "revoked", code: http.StatusGone},
+		{url: "https://example.com/revoked.png", code: http.StatusGone},
+		{url: "https://example.com/cat.png", jti: "unavailable", code: http.StatusServiceUnavailable},
+	} {
+		signed, err := client.Sign(context.Background(), c.url, SignOption{
+			JwtKid: defaultKid,
+			JwtID:  c.jti,
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		w := httptest.NewRecorder()
+		chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signed, nil))
+		if w.Code != c.code {
+			t.Errorf("%q, %q: expect %d, got %d", c.url, c.jti, c.code, w.Code)
+		}
+	}
+}
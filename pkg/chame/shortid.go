@@ -0,0 +1,110 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrShortIDNotFound is returned by ShortIDStore when a short ID is
+	// unknown or expired.
+	ErrShortIDNotFound = errors.New("chame: short ID not found")
+	// ErrShortIDConflict is returned by ShortIDStore when a short ID is
+	// already mapped to another token.
+	ErrShortIDConflict = errors.New("chame: short ID already in use")
+)
+
+// ShortIDStore maps short IDs to signed tokens, so that signed URLs can be
+// shortened to "/i/<short ID>". Tokens are verified on every request as if
+// they were in URLs, and ShortIDStore needs not to be trusted.
+type ShortIDStore interface {
+	// PutToken maps id to token until expiresAt, or forever if expiresAt is
+	// zero. PutToken returns ErrShortIDConflict if id is already mapped to
+	// a different token.
+	PutToken(ctx context.Context, id string, token string, expiresAt time.Time) error
+	// GetToken returns the token mapped to id, or ErrShortIDNotFound if id
+	// is unknown or expired.
+	GetToken(ctx context.Context, id string) (string, error)
+}
+
+// shortIDLen is the number of bytes of short IDs before encoded in base64.
+const shortIDLen = 12
+
+// isShortID reports whether s is a short ID rather than a token.
+func isShortID(s string) bool {
+	return s != "" && !strings.Contains(s, ".")
+}
+
+// newShortID returns a short ID for token. If key is not nil, the ID is
+// derived from token with HMAC-SHA256 so that the same token always has the
+// same ID. Otherwise, the ID is random.
+func newShortID(key []byte, token string) (string, error) {
+	var b []byte
+	if key != nil {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(token))
+		b = mac.Sum(nil)[:shortIDLen]
+	} else {
+		b = make([]byte, shortIDLen)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("chame: failed to generate a short ID: %w", err)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SignShort signs url like Sign, and maps the token to a short ID in ids to
+// return the short URL. The ID is derived from the token if
+// opts.ShortIDKey is not nil, or random otherwise.
+func (cli *Client) SignShort(ctx context.Context, ids ShortIDStore, url string, opts SignOption) (string, error) {
+	signer, err := newTokenSigner(ctx, cli.store, cli.issuer, opts.JwtKid, opts.Algorithm, opts.Encryption)
+	if err != nil {
+		return "", err
+	}
+	signed, err := cli.sign(signer, url, &opts)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimPrefix(signed, cli.baseUrl+proxyPrefix)
+
+	expiresAt := opts.NotAfter
+	if expiresAt.IsZero() {
+		expiresAt = opts.Expiry
+	}
+	// NOTE(yosida95): a random ID may conflict with existing ones in a tiny
+	// probability, which is retried with another ID.
+	for i := 0; ; i++ {
+		id, err := newShortID(opts.ShortIDKey, token)
+		if err != nil {
+			return "", err
+		}
+		err = ids.PutToken(ctx, id, token, expiresAt)
+		if err == nil {
+			return cli.baseUrl + proxyPrefix + id, nil
+		}
+		if !errors.Is(err, ErrShortIDConflict) || opts.ShortIDKey != nil || i == 2 {
+			return "", fmt.Errorf("chame: failed to store a short ID: %w", err)
+		}
+	}
+}
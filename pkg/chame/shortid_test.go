@@ -0,0 +1,121 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mapShortIDs map[string]string
+
+func (ids mapShortIDs) PutToken(_ context.Context, id string, token string, _ time.Time) error {
+	if old, ok := ids[id]; ok && old != token {
+		return ErrShortIDConflict
+	}
+	ids[id] = token
+	return nil
+}
+
+func (ids mapShortIDs) GetToken(_ context.Context, id string) (string, error) {
+	if id == "unavailable" {
+		return "", errors.New("backend is down")
+	}
+	token, ok := ids[id]
+	if !ok {
+		return "", ErrShortIDNotFound
+	}
+	return token, nil
+}
+
+func TestClientSignShort(t *testing.T) {
+	client, err := NewClient("https://chame.example.net", "https://chame.example.net", keyStore)
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	ids := mapShortIDs{}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintln(w, req.URL.String())
+		}),
+		Store:            keyStore,
+		ExtraContentType: []string{"text/plain"},
+		ShortIDs:         ids,
+	}
+
+	random1, err := client.SignShort(context.Background(), ids, "https://example.net/cat.jpeg", SignOption{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	random2, _ := client.SignShort(context.Background(), ids, "https://example.net/cat.jpeg", SignOption{})
+	if random1 == random2 {
+		t.Errorf("random short IDs must differ: %q", random1)
+	}
+	opts := SignOption{ShortIDKey: []byte("shortidkey")}
+	derived1, _ := client.SignShort(context.Background(), ids, "https://example.net/dog.jpeg", opts)
+	derived2, _ := client.SignShort(context.Background(), ids, "https://example.net/dog.jpeg", opts)
+	if derived1 != derived2 {
+		t.Errorf("derived short IDs must be the same: %q, %q", derived1, derived2)
+	}
+
+	for _, c := range []struct {
+		p        string
+		code     int
+		contains string
+	}{
+		{
+			p:        strings.TrimPrefix(random1, client.BaseURL()),
+			code:     http.StatusOK,
+			contains: "https://example.net/cat.jpeg",
+		},
+		{
+			p:        strings.TrimPrefix(derived1, client.BaseURL()),
+			code:     http.StatusOK,
+			contains: "https://example.net/dog.jpeg",
+		},
+		{
+			p:        proxyPrefix + "unknown",
+			code:     http.StatusNotFound,
+			contains: "404",
+		},
+		{
+			p:        proxyPrefix + "unavailable",
+			code:     http.StatusServiceUnavailable,
+			contains: "Service Unavailable",
+		},
+		{
+			p:        signedPath(t, "https://example.net/cat.jpeg"),
+			code:     http.StatusOK,
+			contains: "https://example.net/cat.jpeg",
+		},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.p, nil)
+		chame.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%s: expect %d, got %d", c.p, c.code, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), c.contains) {
+			t.Errorf("%s: %q not found", c.p, c.contains)
+		}
+	}
+}
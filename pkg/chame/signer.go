@@ -0,0 +1,137 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signerMethod is jwt.SigningMethod that signs with crypto.Signer, which may
+// hold its private key outside the process such as in a hardware token or a
+// remote signing service. Verification is delegated to the standard method of
+// the same algorithm.
+type signerMethod struct {
+	jwt.SigningMethod
+	hash crypto.Hash
+	// pss is set for RSASSA-PSS.
+	pss bool
+	// size is the byte length of each of R and S for ECDSA.
+	size int
+}
+
+var (
+	signerRS256 = &signerMethod{SigningMethod: jwt.SigningMethodRS256, hash: crypto.SHA256}
+	signerRS384 = &signerMethod{SigningMethod: jwt.SigningMethodRS384, hash: crypto.SHA384}
+	signerRS512 = &signerMethod{SigningMethod: jwt.SigningMethodRS512, hash: crypto.SHA512}
+	signerPS256 = &signerMethod{SigningMethod: jwt.SigningMethodPS256, hash: crypto.SHA256, pss: true}
+	signerPS384 = &signerMethod{SigningMethod: jwt.SigningMethodPS384, hash: crypto.SHA384, pss: true}
+	signerPS512 = &signerMethod{SigningMethod: jwt.SigningMethodPS512, hash: crypto.SHA512, pss: true}
+	signerES256 = &signerMethod{SigningMethod: jwt.SigningMethodES256, hash: crypto.SHA256, size: 32}
+	signerES384 = &signerMethod{SigningMethod: jwt.SigningMethodES384, hash: crypto.SHA384, size: 48}
+	signerES512 = &signerMethod{SigningMethod: jwt.SigningMethodES512, hash: crypto.SHA512, size: 66}
+	signerEdDSA = &signerMethod{SigningMethod: jwt.SigningMethodEdDSA}
+)
+
+// signerMethods returns the signing methods for signer inferred from its
+// public key.
+func signerMethods(signer crypto.Signer) ([]jwt.SigningMethod, error) {
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return []jwt.SigningMethod{
+			signerRS256, signerRS384, signerRS512,
+			signerPS256, signerPS384, signerPS512,
+		}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return []jwt.SigningMethod{signerES256}, nil
+		case elliptic.P384():
+			return []jwt.SigningMethod{signerES384}, nil
+		case elliptic.P521():
+			return []jwt.SigningMethod{signerES512}, nil
+		}
+		return nil, fmt.Errorf("chame: unsupported elliptic curve")
+	case ed25519.PublicKey:
+		return []jwt.SigningMethod{signerEdDSA}, nil
+	}
+	return nil, fmt.Errorf("chame: unsupported key algorithm")
+}
+
+func (m *signerMethod) Sign(signingString string, key interface{}) (string, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	digest := []byte(signingString)
+	var opts crypto.SignerOpts = crypto.Hash(0)
+	if m.hash != 0 {
+		if !m.hash.Available() {
+			return "", jwt.ErrHashUnavailable
+		}
+		h := m.hash.New()
+		h.Write(digest)
+		digest = h.Sum(nil)
+		opts = m.hash
+	}
+	if m.pss {
+		opts = &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       m.hash,
+		}
+	}
+	sig, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return "", err
+	}
+	if m.size > 0 {
+		// crypto.Signer returns an ASN.1 DER-encoded ECDSA signature,
+		// while JWS requires R and S concatenated in fixed length.
+		sig, err = ecdsaRawSignature(sig, m.size)
+		if err != nil {
+			return "", err
+		}
+	}
+	return jwt.EncodeSegment(sig), nil
+}
+
+func ecdsaRawSignature(der []byte, size int) ([]byte, error) {
+	var parsed struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(der, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("malformed ECDSA signature: %w", err)
+	}
+	if len(rest) > 0 || parsed.R.Sign() <= 0 || parsed.S.Sign() <= 0 ||
+		parsed.R.BitLen() > size*8 || parsed.S.BitLen() > size*8 {
+		return nil, errors.New("malformed ECDSA signature")
+	}
+	raw := make([]byte, 2*size)
+	parsed.R.FillBytes(raw[:size])
+	parsed.S.FillBytes(raw[size:])
+	return raw, nil
+}
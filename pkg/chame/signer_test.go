@@ -0,0 +1,137 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// opaqueSigner hides the type of the private key as if it is held outside
+// the process.
+type opaqueSigner struct {
+	signer crypto.Signer
+	err    error
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey { return s.signer.Public() }
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestCryptoSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKeys := make(map[elliptic.Curve]*ecdsa.PrivateKey)
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		ecKeys[curve], err = ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		key crypto.Signer
+		opt string
+		alg string
+	}{
+		{key: rsaKey, alg: "RS256"},
+		{key: rsaKey, opt: "RS512", alg: "RS512"},
+		{key: rsaKey, opt: "PS256", alg: "PS256"},
+		{key: rsaKey, opt: "PS384", alg: "PS384"},
+		{key: ecKeys[elliptic.P256()], alg: "ES256"},
+		{key: ecKeys[elliptic.P384()], alg: "ES384"},
+		{key: ecKeys[elliptic.P521()], alg: "ES512"},
+		{key: edKey, alg: "EdDSA"},
+	} {
+		store := &keyPairStore{
+			signing:   &opaqueSigner{signer: c.key},
+			verifying: c.key.Public(),
+		}
+		// ECDSA signatures are randomized, and some of R and S have
+		// leading zeros to be padded.
+		for i := 0; i < 8; i++ {
+			encoded, err := encodeClaims(context.Background(), store, &Claims{Token: Token{
+				Issuer:  defaultIss,
+				Subject: "https://example.com/foo.png",
+			}}, "", c.opt)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", c.alg, err)
+			}
+			var header struct {
+				Alg string `json:"alg"`
+			}
+			segment, _, _ := strings.Cut(encoded, ".")
+			if b, err := base64.RawURLEncoding.DecodeString(segment); err != nil || json.Unmarshal(b, &header) != nil {
+				t.Fatalf("%s: malformed header: %q", c.alg, segment)
+			} else if header.Alg != c.alg {
+				t.Fatalf("expect %q, got %q", c.alg, header.Alg)
+			}
+			if _, err := DecodeToken(context.Background(), store, encoded); err != nil {
+				t.Fatalf("%s: failed to verify: %v", c.alg, err)
+			}
+		}
+	}
+
+	// compact tokens
+	store := &keyPairStore{
+		signing:   &opaqueSigner{signer: ecKeys[elliptic.P256()]},
+		verifying: ecKeys[elliptic.P256()].Public(),
+	}
+	client, err := NewClient("https://chame.yosida95.com", defaultIss, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := client.Sign(context.Background(), "https://example.com/foo.png", SignOption{Compact: true})
+	if err != nil {
+		t.Fatalf("compact: unexpected error: %v", err)
+	}
+	token := strings.TrimPrefix(signed, client.BaseURL()+proxyPrefix)
+	if _, err := DecodeToken(context.Background(), store, token); err != nil {
+		t.Errorf("compact: failed to verify: %v", err)
+	}
+
+	// errors of the signer are returned
+	errSign := errors.New("signing service is down")
+	store = &keyPairStore{signing: &opaqueSigner{signer: edKey, err: errSign}}
+	if _, err := encodeClaims(context.Background(), store, &Claims{}, "", ""); !errors.Is(err, errSign) {
+		t.Errorf("expect %v, got %v", errSign, err)
+	}
+	store = &keyPairStore{signing: &opaqueSigner{signer: rsaKey}}
+	if _, err := encodeClaims(context.Background(), store, &Claims{}, "", "ES256"); err == nil {
+		t.Errorf("ES256 must not be used with RSA keys")
+	}
+}
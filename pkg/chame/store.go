@@ -14,16 +14,142 @@
 
 package chame
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
 type Store interface {
 	// GetVerifyingKey retrieves a key would be used to verify signed URLs by
 	// a combination of Issuer (the "iss" claim) and Key ID (the "kid" header
 	// value).
 	// If an appropriate key is found, GetVerifyingKey returns a non-nil key
-	// (the its type must be []byte for HMAC, *rsa.Publickey for RSA, or
-	// *ecdsa.PublicKey for ECDSA) as the first return value, and nil err as
-	// the second return value.
+	// (the its type must be []byte for HMAC, *rsa.Publickey for RSA,
+	// *ecdsa.PublicKey for ECDSA, or ed25519.PublicKey for EdDSA) as the
+	// first return value, and nil err as the second return value.
 	// Otherwise GetVerifyingKey returns non-nil err as the second.
 	GetVerifyingKey(iss string, kid string) (key interface{}, err error)
 
+	// GetSigningKey retrieves a key would be used to sign URLs. Its type
+	// must be []byte for HMAC, *rsa.PrivateKey for RSA, *ecdsa.PrivateKey
+	// for ECDSA, or ed25519.PrivateKey for EdDSA. Otherwise it may be
+	// crypto.Signer whose private key is held elsewhere, and the algorithm
+	// is inferred from its public key.
 	GetSigningKey(iss string, kid string) (key interface{}, err error)
 }
+
+// Key is key material with metadata. Store may return *Key or KeySet in
+// place of bare key material.
+type Key struct {
+	// Material is key material of a type accepted by Store.
+	Material interface{}
+	// Algorithm is the JWS algorithm (the "alg" header value) used with the
+	// key. If Algorithm is empty, signing uses the default algorithm for the
+	// type of Material and verification accepts any algorithm for it.
+	// Otherwise verification accepts only Algorithm.
+	Algorithm string
+
+	// Name identifies the key in logs and Claims.KeyName. It is not sent
+	// in tokens unlike the key ID.
+	Name string
+	// NotBefore and NotAfter limit the period in which the key is used, so
+	// that keys can be rotated with overlap. Zero values mean no limit.
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// validAt reports whether key is valid at t.
+func (key *Key) validAt(t time.Time) bool {
+	return (key.NotBefore.IsZero() || !t.Before(key.NotBefore)) &&
+		(key.NotAfter.IsZero() || t.Before(key.NotAfter))
+}
+
+// KeySet is a set of keys that Store may return in place of a single key.
+// Tokens are verified with all the keys valid at the moment, and signed with
+// the first one of them.
+type KeySet []*Key
+
+// EncryptingStore is a Store that also provides keys to encrypt and decrypt
+// tokens. See EncodeEncryptedToken.
+type EncryptingStore interface {
+	Store
+
+	// GetEncryptingKey retrieves a key used to encrypt tokens. Its type
+	// must be []byte for direct encryption or AES key wrap, *rsa.PublicKey
+	// for RSA-OAEP-256, or *ecdsa.PublicKey for ECDH-ES+A256KW.
+	GetEncryptingKey(iss string, kid string) (key interface{}, err error)
+
+	// GetDecryptingKey retrieves a key used to decrypt tokens. Its type
+	// must be []byte, *rsa.PrivateKey or *ecdsa.PrivateKey correspondingly.
+	// It returns ErrKeyNotFound if no key is found, and any other error
+	// makes the token rejected as temporarily unavailable.
+	GetDecryptingKey(iss string, kid string) (key interface{}, err error)
+}
+
+// DecryptingStoreContext is implemented by an EncryptingStore whose
+// decrypting keys are retrieved honoring deadlines and cancellation of ctx.
+// GetDecryptingKeyContext is used in preference to GetDecryptingKey.
+type DecryptingStoreContext interface {
+	GetDecryptingKeyContext(ctx context.Context, iss string, kid string) (key interface{}, err error)
+}
+
+// ErrKeyNotFound is returned by StoreContext when no key is found for the
+// issuer and the key ID. Any other error means that the key is unavailable
+// at the moment, such as due to an outage of the backend.
+var ErrKeyNotFound = errors.New("chame: key not found")
+
+// errKeyUnavailable is returned when StoreContext fails with an error other
+// than ErrKeyNotFound.
+var errKeyUnavailable = errors.New("chame: failed to retrieve a key")
+
+// StoreContext is like Store but honors deadlines and cancellation of ctx. A
+// Store may also implement StoreContext, whose methods are used in
+// preference to those of Store.
+type StoreContext interface {
+	// GetVerifyingKeyContext is like Store.GetVerifyingKey. It returns
+	// ErrKeyNotFound if no key is found.
+	GetVerifyingKeyContext(ctx context.Context, iss string, kid string) (key interface{}, err error)
+	// GetSigningKeyContext is like Store.GetSigningKey. It returns
+	// ErrKeyNotFound if no key is found.
+	GetSigningKeyContext(ctx context.Context, iss string, kid string) (key interface{}, err error)
+}
+
+// NewStoreContext returns store as StoreContext. If store does not implement
+// StoreContext, its errors are regarded as ErrKeyNotFound since Store has no
+// way to tell an unknown key from a failure.
+func NewStoreContext(store Store) StoreContext {
+	if sc, ok := store.(StoreContext); ok {
+		return sc
+	}
+	return storeAdapter{store}
+}
+
+type storeAdapter struct {
+	store Store
+}
+
+func (a storeAdapter) GetVerifyingKeyContext(_ context.Context, iss string, kid string) (interface{}, error) {
+	key, err := a.store.GetVerifyingKey(iss, kid)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		err = fmt.Errorf("%w: %w", ErrKeyNotFound, err)
+	}
+	return key, err
+}
+
+func (a storeAdapter) GetSigningKeyContext(_ context.Context, iss string, kid string) (interface{}, error) {
+	key, err := a.store.GetSigningKey(iss, kid)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		err = fmt.Errorf("%w: %w", ErrKeyNotFound, err)
+	}
+	return key, err
+}
+
+// keyLookupError classifies an error of StoreContext for verification.
+func keyLookupError(err error) error {
+	if errors.Is(err, ErrKeyNotFound) {
+		return &tokenError{ErrUnknownKey, err}
+	}
+	return &tokenError{errKeyUnavailable, err}
+}
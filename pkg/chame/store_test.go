@@ -15,7 +15,12 @@
 package chame
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
 )
 
 var store = &mockstore{
@@ -40,3 +45,70 @@ func (store *mockstore) GetVerifyingKey(iss string, kid string) (interface{}, er
 func (store *mockstore) GetSigningKey(iss string, kid string) (interface{}, error) {
 	return store.GetVerifyingKey(iss, kid)
 }
+
+// ctxstore is a StoreContext whose backend is down for the "down" key ID.
+type ctxstore struct {
+	*mockstore
+	ctxKey interface{}
+	seen   interface{}
+}
+
+func (store *ctxstore) GetVerifyingKeyContext(ctx context.Context, iss string, kid string) (interface{}, error) {
+	store.seen = ctx.Value(store.ctxKey)
+	if kid == "down" {
+		return nil, errors.New("backend is down")
+	}
+	key, err := store.GetVerifyingKey(iss, kid)
+	if err != nil {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (store *ctxstore) GetSigningKeyContext(ctx context.Context, iss string, kid string) (interface{}, error) {
+	return store.GetVerifyingKeyContext(ctx, iss, kid)
+}
+
+func TestStoreContext(t *testing.T) {
+	type ctxKey struct{}
+	sc := &ctxstore{mockstore: store, ctxKey: ctxKey{}}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "text/plain")
+		}),
+		Store:            sc,
+		ExtraContentType: []string{"text/plain"},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	if _, err := EncodeToken(ctx, sc, &Token{Issuer: defaultIss}, defaultKid); err != nil || sc.seen != "value" {
+		t.Errorf("context is not passed to GetSigningKeyContext: %v", err)
+	}
+	for _, c := range []struct {
+		kid  string
+		code int
+	}{
+		{kid: defaultKid, code: http.StatusOK},
+		{kid: "unknown", code: http.StatusNotFound},
+		{kid: "down", code: http.StatusServiceUnavailable},
+	} {
+		token, err := encodeClaims(context.Background(), &keyPairStore{signing: store.key}, &Claims{Token: Token{
+			Issuer:  defaultIss,
+			Subject: "https://example.com/cat.png",
+		}}, c.kid, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, proxyPrefix+token, nil)
+		chame.ServeHTTP(w, req.WithContext(ctx))
+		if w.Code != c.code {
+			t.Errorf("%s: expect %d, got %d", c.kid, c.code, w.Code)
+		}
+	}
+
+	// errors of Store without StoreContext are regarded as unknown keys
+	if _, err := NewStoreContext(store).GetVerifyingKeyContext(ctx, defaultIss, "unknown"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, have %v", err)
+	}
+}
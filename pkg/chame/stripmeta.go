@@ -0,0 +1,452 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+)
+
+// orientationQuality is the JPEG quality used to re-encode images rotated by
+// ApplyOrientation.
+const orientationQuality = 90
+
+func (chame *Chame) stripMetadataFilter(ctype string) (bodyFilter, bool) {
+	if !chame.StripMetadata {
+		return bodyFilter{}, false
+	}
+	var strip func(io.Writer, []byte) error
+	switch ctype {
+	case "image/jpeg", "image/jpg":
+		if !chame.ApplyOrientation {
+			return bodyFilter{
+				required: true,
+				stream: func(dst io.Writer, src io.Reader) error {
+					_, err := stripJPEG(dst, src)
+					return err
+				},
+			}, true
+		}
+		strip = func(w io.Writer, body []byte) error {
+			return stripJPEGMetadata(w, body, true)
+		}
+	case "image/png":
+		return bodyFilter{
+			required: true,
+			stream:   stripPNGMetadata,
+		}, true
+	case "image/webp":
+		strip = stripWebPMetadata
+	default:
+		return bodyFilter{}, false
+	}
+	return bodyFilter{
+		required: true,
+		apply: func(hdr http.Header, body []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			buf.Grow(len(body))
+			if err := strip(&buf, body); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+	}, true
+}
+
+var errMalformedJPEG = errors.New("chame: malformed JPEG")
+
+type jpegSegment struct {
+	marker  byte
+	payload []byte
+}
+
+// readJPEGSegment reads a marker and its payload. The payload is nil for
+// standalone markers.
+func readJPEGSegment(r *bufio.Reader) (jpegSegment, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return jpegSegment{}, err
+	} else if b != 0xff {
+		return jpegSegment{}, errMalformedJPEG
+	}
+	return readJPEGMarker(r)
+}
+
+// readJPEGMarker is like readJPEGSegment, but the leading 0xff has already
+// been read.
+func readJPEGMarker(r *bufio.Reader) (jpegSegment, error) {
+	var seg jpegSegment
+	b := byte(0xff)
+	for b == 0xff {
+		var err error
+		if b, err = r.ReadByte(); err != nil {
+			return seg, err
+		}
+	}
+	seg.marker = b
+	if b == 0x01 || b >= 0xd0 && b <= 0xd9 {
+		return seg, nil
+	}
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return seg, err
+	} else if n < 2 {
+		return seg, errMalformedJPEG
+	}
+	seg.payload = make([]byte, n-2)
+	if _, err := io.ReadFull(r, seg.payload); err != nil {
+		return seg, err
+	}
+	return seg, nil
+}
+
+func writeJPEGSegment(w io.Writer, seg jpegSegment) error {
+	if seg.payload == nil && seg.marker != 0xda {
+		_, err := w.Write([]byte{0xff, seg.marker})
+		return err
+	}
+	n := len(seg.payload) + 2
+	if _, err := w.Write([]byte{0xff, seg.marker, byte(n >> 8), byte(n)}); err != nil {
+		return err
+	}
+	_, err := w.Write(seg.payload)
+	return err
+}
+
+// isJPEGMetadata reports whether seg is an application segment or a comment
+// other than JFIF, ICC profiles and the Adobe segment required for decoding.
+func isJPEGMetadata(seg jpegSegment) bool {
+	switch m := seg.marker; {
+	case m == 0xe0, m == 0xee:
+		return false
+	case m == 0xe2:
+		return !bytes.HasPrefix(seg.payload, []byte("ICC_PROFILE\x00"))
+	case m >= 0xe1 && m <= 0xef, m == 0xfe:
+		return true
+	}
+	return false
+}
+
+// stripJPEG copies a JPEG stream from src to dst, removing metadata segments.
+// It returns the EXIF orientation of the image, or 0 if not specified.
+func stripJPEG(dst io.Writer, src io.Reader) (int, error) {
+	r := bufio.NewReader(src)
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return 0, errMalformedJPEG
+	}
+	if _, err := dst.Write(soi[:]); err != nil {
+		return 0, err
+	}
+
+	orientation := 0
+	seg, err := readJPEGSegment(r)
+	for {
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", errMalformedJPEG, err)
+		}
+		if seg.marker == 0xe1 && orientation == 0 {
+			orientation = exifOrientation(seg.payload)
+		}
+		if !isJPEGMetadata(seg) {
+			if err := writeJPEGSegment(dst, seg); err != nil {
+				return 0, err
+			}
+		}
+		switch seg.marker {
+		case 0xda:
+			seg, err = copyJPEGScan(dst, r)
+		case 0xd9:
+			// NOTE(yosida95): data following EOI, such as secondary
+			// images of MPF which have their own metadata, is dropped.
+			return orientation, nil
+		default:
+			seg, err = readJPEGSegment(r)
+		}
+	}
+}
+
+// copyJPEGScan copies entropy-coded data following SOS from r to dst, and
+// returns the segment that ends it.
+func copyJPEGScan(dst io.Writer, r *bufio.Reader) (jpegSegment, error) {
+	for {
+		data, err := r.ReadSlice(0xff)
+		if err == bufio.ErrBufferFull {
+			if _, err := dst.Write(data); err != nil {
+				return jpegSegment{}, err
+			}
+			continue
+		} else if err != nil {
+			return jpegSegment{}, err
+		}
+		next, err := r.Peek(1)
+		if err != nil {
+			return jpegSegment{}, err
+		}
+		// A stuffed zero byte and restart markers are part of the data.
+		if b := next[0]; b == 0x00 || b >= 0xd0 && b <= 0xd7 {
+			if _, err := dst.Write(data); err != nil {
+				return jpegSegment{}, err
+			}
+			if _, err := dst.Write(next); err != nil {
+				return jpegSegment{}, err
+			}
+			r.Discard(1)
+			continue
+		}
+		if _, err := dst.Write(data[:len(data)-1]); err != nil {
+			return jpegSegment{}, err
+		}
+		return readJPEGMarker(r)
+	}
+}
+
+// exifOrientation returns the value of the Orientation tag in IFD0 of an
+// APP1 payload, or 0 if not found.
+func exifOrientation(payload []byte) int {
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// Orientation is of type SHORT and stored in the value field.
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+func stripJPEGMetadata(w io.Writer, body []byte, applyOrientation bool) error {
+	if !applyOrientation {
+		_, err := stripJPEG(w, bytes.NewReader(body))
+		return err
+	}
+
+	var stripped bytes.Buffer
+	orientation, err := stripJPEG(&stripped, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if orientation <= 1 {
+		_, err := w.Write(stripped.Bytes())
+		return err
+	}
+
+	// Refuse to decode images that transcoding would not decode either,
+	// since the image cannot be upright without re-encoding.
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(stripped.Bytes()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMalformedJPEG, err)
+	}
+	if cfg.Width*cfg.Height > maxTranscodePixels {
+		return fmt.Errorf("%w: %dx%d pixels to orient", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	m, err := jpeg.Decode(bytes.NewReader(stripped.Bytes()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMalformedJPEG, err)
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, orient(m, orientation), &jpeg.Options{Quality: orientationQuality}); err != nil {
+		return err
+	}
+
+	// Carry ICC profiles over to the re-encoded image, right after its SOI.
+	if _, err := w.Write(encoded.Next(2)); err != nil {
+		return err
+	}
+	r := bufio.NewReader(bytes.NewReader(stripped.Bytes()[2:]))
+	for {
+		seg, err := readJPEGSegment(r)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errMalformedJPEG, err)
+		}
+		if seg.marker == 0xda || seg.marker == 0xd9 {
+			break
+		}
+		if seg.marker == 0xe2 {
+			if err := writeJPEGSegment(w, seg); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = w.Write(encoded.Bytes())
+	return err
+}
+
+// orient transforms m as specified by an EXIF orientation value so that it
+// becomes upright.
+func orient(m image.Image, orientation int) image.Image {
+	b := m.Bounds()
+	// sw and sh are the size of m, and w and h are of the result.
+	sw, sh := b.Dx(), b.Dy()
+	var src func(x, y int) (int, int)
+	switch orientation {
+	case 2:
+		src = func(x, y int) (int, int) { return sw - 1 - x, y }
+	case 3:
+		src = func(x, y int) (int, int) { return sw - 1 - x, sh - 1 - y }
+	case 4:
+		src = func(x, y int) (int, int) { return x, sh - 1 - y }
+	case 5:
+		src = func(x, y int) (int, int) { return y, x }
+	case 6:
+		src = func(x, y int) (int, int) { return y, sh - 1 - x }
+	case 7:
+		src = func(x, y int) (int, int) { return sw - 1 - y, sh - 1 - x }
+	case 8:
+		src = func(x, y int) (int, int) { return sw - 1 - y, x }
+	default:
+		return m
+	}
+	w, h := sw, sh
+	if orientation >= 5 {
+		w, h = sh, sw
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := src(x, y)
+			dst.Set(x, y, m.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+var errMalformedPNG = errors.New("chame: malformed PNG")
+
+// pngMetadataChunk is a set of PNG chunk types removed by stripPNGMetadata.
+var pngMetadataChunk = map[string]bool{
+	"eXIf": true,
+	"iTXt": true,
+	"tEXt": true,
+	"tIME": true,
+	"zTXt": true,
+}
+
+// stripPNGMetadata copies a PNG stream from src to dst, removing metadata
+// chunks.
+func stripPNGMetadata(dst io.Writer, src io.Reader) error {
+	const signature = "\x89PNG\r\n\x1a\n"
+	r := bufio.NewReader(src)
+	var sig [len(signature)]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil || string(sig[:]) != signature {
+		return errMalformedPNG
+	}
+	if _, err := dst.Write(sig[:]); err != nil {
+		return err
+	}
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return fmt.Errorf("%w: %v", errMalformedPNG, err)
+		}
+		// length of data followed by CRC
+		n := int64(binary.BigEndian.Uint32(hdr[:4])) + 4
+		typ := string(hdr[4:])
+		if pngMetadataChunk[typ] {
+			if _, err := io.CopyN(io.Discard, r, n); err != nil {
+				return fmt.Errorf("%w: %v", errMalformedPNG, err)
+			}
+			continue
+		}
+		if _, err := dst.Write(hdr[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, r, n); err != nil {
+			return fmt.Errorf("%w: %v", errMalformedPNG, err)
+		}
+		if typ == "IEND" {
+			return nil
+		}
+	}
+}
+
+var errMalformedWebP = errors.New("chame: malformed WebP")
+
+// stripWebPMetadata removes EXIF and XMP chunks from a WebP image. Unlike
+// JPEG and PNG, it requires the whole image to update the RIFF header.
+func stripWebPMetadata(dst io.Writer, body []byte) error {
+	if len(body) < 12 || string(body[:4]) != "RIFF" || string(body[8:12]) != "WEBP" {
+		return errMalformedWebP
+	}
+	size := int(binary.LittleEndian.Uint32(body[4:]))
+	if size < 4 || size > len(body)-8 {
+		return errMalformedWebP
+	}
+
+	var chunks bytes.Buffer
+	for rest := body[12 : 8+size]; len(rest) > 0; {
+		if len(rest) < 8 {
+			return errMalformedWebP
+		}
+		n := int(binary.LittleEndian.Uint32(rest[4:]))
+		end := 8 + n + n&1
+		if n < 0 || end > len(rest) {
+			return errMalformedWebP
+		}
+		chunk := rest[:end]
+		rest = rest[end:]
+		switch string(chunk[:4]) {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			if n < 1 {
+				return errMalformedWebP
+			}
+			chunk = append([]byte(nil), chunk...)
+			// clear EXIF and XMP flags
+			chunk[8] &^= 0x08 | 0x04
+		}
+		chunks.Write(chunk)
+	}
+
+	var hdr [12]byte
+	copy(hdr[:], body[:12])
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(4+chunks.Len()))
+	if _, err := dst.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := dst.Write(chunks.Bytes())
+	return err
+}
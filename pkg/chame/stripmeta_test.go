@@ -0,0 +1,294 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// exifSegment returns an APP1 segment which has only the Orientation tag.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	return jpegSegmentBytes(0xe1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func jpegSegmentBytes(marker byte, payload []byte) []byte {
+	var buf bytes.Buffer
+	writeJPEGSegment(&buf, jpegSegment{marker: marker, payload: payload})
+	return buf.Bytes()
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	icc := jpegSegmentBytes(0xe2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+	xmp := jpegSegmentBytes(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	iptc := jpegSegmentBytes(0xed, []byte("Photoshop 3.0\x00"))
+	comment := jpegSegmentBytes(0xfe, []byte("camera serial"))
+
+	var in bytes.Buffer
+	in.Write(encoded.Next(2))
+	for _, seg := range [][]byte{exifSegment(6), icc, xmp, iptc, comment} {
+		in.Write(seg)
+	}
+	in.Write(encoded.Bytes())
+
+	for _, c := range []struct {
+		apply         bool
+		width, height int
+	}{
+		{apply: false, width: 4, height: 2},
+		{apply: true, width: 2, height: 4},
+	} {
+		var out bytes.Buffer
+		if err := stripJPEGMetadata(&out, in.Bytes(), c.apply); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		for _, seg := range [][]byte{exifSegment(6), xmp, iptc, comment} {
+			if bytes.Contains(out.Bytes(), seg) {
+				t.Errorf("metadata remains: %q", seg)
+			}
+		}
+		if !bytes.Contains(out.Bytes(), icc) {
+			t.Errorf("ICC profile removed")
+		}
+		cfg, err := jpeg.DecodeConfig(&out)
+		if err != nil {
+			t.Errorf("failed to decode stripped image: %v", err)
+			continue
+		}
+		if cfg.Width != c.width || cfg.Height != c.height {
+			t.Errorf("expect %dx%d, got %dx%d", c.width, c.height, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestStripJPEGMetadata_tooLarge(t *testing.T) {
+	encoded := gradientJPEG(t, 8, 8)
+	// declare 65535x65535 pixels in SOF0
+	sof := bytes.Index(encoded, []byte{0xff, 0xc0})
+	if sof < 0 {
+		t.Fatal("SOF0 not found")
+	}
+	copy(encoded[sof+5:], []byte{0xff, 0xff, 0xff, 0xff})
+
+	var out bytes.Buffer
+	if err := stripJPEGMetadata(&out, withEXIF(encoded, 6), true); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("expect %v, got %v", ErrImageTooLarge, err)
+	}
+}
+
+// gradientJPEG encodes a non-uniform image so that its entropy-coded data
+// is not trivial.
+func gradientJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	m := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			m.Set(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x * y), A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF inserts an EXIF segment of orientation right after SOI.
+func withEXIF(encoded []byte, orientation uint16) []byte {
+	in := append([]byte(nil), encoded[:2]...)
+	in = append(in, exifSegment(orientation)...)
+	return append(in, encoded[2:]...)
+}
+
+func TestStripJPEG_trailingData(t *testing.T) {
+	primary := gradientJPEG(t, 64, 48)
+	// a secondary image of MPF appended after EOI
+	secondary := withEXIF(gradientJPEG(t, 8, 8), 3)
+	in := append(withEXIF(primary, 6), secondary...)
+
+	var out bytes.Buffer
+	if _, err := stripJPEG(&out, bytes.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), primary) {
+		t.Errorf("expect the primary image without metadata")
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 3x2 pixels of
+	//   1  2  3
+	//  11 12 13
+	m := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(m.Pix, []byte{1, 2, 3, 11, 12, 13})
+
+	for orientation, expect := range map[int][][]uint8{
+		1: {{1, 2, 3}, {11, 12, 13}},
+		2: {{3, 2, 1}, {13, 12, 11}},
+		3: {{13, 12, 11}, {3, 2, 1}},
+		4: {{11, 12, 13}, {1, 2, 3}},
+		5: {{1, 11}, {2, 12}, {3, 13}},
+		6: {{11, 1}, {12, 2}, {13, 3}},
+		7: {{13, 3}, {12, 2}, {11, 1}},
+		8: {{3, 13}, {2, 12}, {1, 11}},
+	} {
+		oriented := orient(m, orientation)
+		b := oriented.Bounds()
+		have := make([][]uint8, b.Dy())
+		for y := range have {
+			have[y] = make([]uint8, b.Dx())
+			for x := range have[y] {
+				have[y][x] = color.GrayModel.Convert(oriented.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+			}
+		}
+		if !reflect.DeepEqual(have, expect) {
+			t.Errorf("orientation %d: expect %v, got %v", orientation, expect, have)
+		}
+	}
+}
+
+func TestChame_StripMetadata(t *testing.T) {
+	primary := gradientJPEG(t, 64, 48)
+	for _, c := range []struct {
+		body   []byte
+		expect int
+	}{
+		{body: withEXIF(primary, 6), expect: http.StatusOK},
+		{body: []byte("not a JPEG image"), expect: http.StatusBadGateway},
+	} {
+		chame := &Chame{
+			Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+				w.Header().Set("Content-Type", "image/jpeg")
+				// written in small pieces as if streamed from the origin
+				io.CopyBuffer(w, bytes.NewReader(c.body), make([]byte, 100))
+			}),
+			Store:         keyStore,
+			StripMetadata: true,
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.jpg"), nil)
+		chame.ServeHTTP(w, req)
+		if w.Code != c.expect {
+			t.Errorf("expect %d, got %d", c.expect, w.Code)
+			continue
+		}
+		if c.expect == http.StatusOK && !bytes.Equal(w.Body.Bytes(), primary) {
+			t.Errorf("metadata must be removed")
+		}
+	}
+
+	// A failure after the response is sent aborts it.
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write(primary[:len(primary)/2])
+		}),
+		Store:         keyStore,
+		StripMetadata: true,
+	}
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("expect %v, got %v", http.ErrAbortHandler, err)
+		}
+	}()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.jpg"), nil)
+	chame.ServeHTTP(w, req)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	text := pngChunk("tEXt", []byte("Author\x00yosida95"))
+	exif := pngChunk("eXIf", []byte("MM\x00\x2a\x00\x00\x00\x08"))
+	icc := pngChunk("iCCP", []byte("icc\x00\x00profile"))
+
+	var in bytes.Buffer
+	in.Write(encoded.Next(8 + 25)) // signature and IHDR
+	in.Write(icc)
+	in.Write(text)
+	in.Write(exif)
+	in.Write(encoded.Bytes())
+
+	var out bytes.Buffer
+	if err := stripPNGMetadata(&out, &in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(out.Bytes(), text) || bytes.Contains(out.Bytes(), exif) {
+		t.Errorf("metadata remains")
+	}
+	if !bytes.Contains(out.Bytes(), icc) {
+		t.Errorf("ICC profile removed")
+	}
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	chunk := func(typ string, data []byte) []byte {
+		c := binary.LittleEndian.AppendUint32([]byte(typ), uint32(len(data)))
+		c = append(c, data...)
+		if len(data)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	riff := func(chunks ...[]byte) []byte {
+		body := bytes.Join(append([][]byte{[]byte("WEBP")}, chunks...), nil)
+		return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body))), body...)
+	}
+	icc := chunk("ICCP", []byte("profile"))
+	vp8l := chunk("VP8L", []byte("image"))
+
+	in := riff(chunk("VP8X", []byte{0x2c, 0, 0, 0, 0, 0, 0, 0, 0, 0}), icc, vp8l, chunk("EXIF", []byte("exif")), chunk("XMP ", []byte("xmp")))
+	expect := riff(chunk("VP8X", []byte{0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0}), icc, vp8l)
+
+	var out bytes.Buffer
+	if err := stripWebPMetadata(&out, in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), expect) {
+		t.Errorf("expect %q, got %q", expect, out.Bytes())
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxSVGDepth is the maximum depth of nested elements in an SVG document.
+const maxSVGDepth = 256
+
+// svgRemovedElement is a set of elements removed from SVG documents along
+// with their descendants.
+var svgRemovedElement = map[string]bool{
+	"embed":         true,
+	"foreignobject": true,
+	"handler":       true,
+	"iframe":        true,
+	"listener":      true,
+	"object":        true,
+	"script":        true,
+}
+
+// sanitizeSVG removes scripts, event handler attributes, javascript: URLs,
+// external references and foreignObject elements from an SVG document. It
+// returns an error if body is not a well-formed SVG document or declares XML
+// entities.
+func sanitizeSVG(body []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = true
+
+	var (
+		out   bytes.Buffer
+		stack []xml.Name
+		skip  int // depth inside a removed element
+		root  bool
+	)
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("chame: malformed SVG: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				if root || !strings.EqualFold(tok.Name.Local, "svg") {
+					return nil, errors.New("chame: malformed SVG: unexpected root element")
+				}
+				root = true
+			}
+			if len(stack) >= maxSVGDepth {
+				return nil, errors.New("chame: malformed SVG: too deeply nested")
+			}
+			stack = append(stack, tok.Name)
+			if skip > 0 || svgRemovedElement[strings.ToLower(tok.Name.Local)] || isSVGScriptAnimation(tok) {
+				skip++
+				continue
+			}
+			writeSVGStartElement(&out, tok)
+		case xml.EndElement:
+			if n := len(stack); n == 0 || stack[n-1] != tok.Name {
+				return nil, errors.New("chame: malformed SVG: unexpected end element")
+			}
+			stack = stack[:len(stack)-1]
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</")
+			writeXMLName(&out, tok.Name)
+			out.WriteByte('>')
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if n := len(stack); n > 0 && strings.EqualFold(stack[n-1].Local, "style") && !isSafeCSS(string(tok)) {
+				continue
+			}
+			xml.EscapeText(&out, tok)
+		case xml.ProcInst:
+			if tok.Target == "xml" && out.Len() == 0 {
+				fmt.Fprintf(&out, "<?xml %s?>", tok.Inst)
+			}
+		case xml.Directive:
+			if bytes.Contains(bytes.ToUpper(tok), []byte("<!ENTITY")) {
+				return nil, errors.New("chame: malformed SVG: entity declarations are not allowed")
+			}
+		case xml.Comment:
+		}
+	}
+	if !root || len(stack) > 0 {
+		return nil, errors.New("chame: malformed SVG: unexpected EOF")
+	}
+	return out.Bytes(), nil
+}
+
+func writeXMLName(w *bytes.Buffer, name xml.Name) {
+	if name.Space != "" {
+		w.WriteString(name.Space)
+		w.WriteByte(':')
+	}
+	w.WriteString(name.Local)
+}
+
+func writeSVGStartElement(w *bytes.Buffer, tok xml.StartElement) {
+	w.WriteByte('<')
+	writeXMLName(w, tok.Name)
+	for _, attr := range tok.Attr {
+		if !isSafeSVGAttr(attr) {
+			continue
+		}
+		w.WriteByte(' ')
+		writeXMLName(w, attr.Name)
+		w.WriteString(`="`)
+		xml.EscapeText(w, []byte(attr.Value))
+		w.WriteByte('"')
+	}
+	w.WriteByte('>')
+}
+
+func isSafeSVGAttr(attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	value := normalizeURL(attr.Value)
+	switch {
+	case strings.HasPrefix(local, "on"):
+		return false
+	case strings.Contains(value, "javascript:"), strings.Contains(value, "vbscript:"):
+		return false
+	case local == "href", local == "src":
+		return isLocalSVGReference(value)
+	case local == "style", strings.Contains(value, "url("):
+		// Presentation attributes such as fill, filter, mask and
+		// clip-path accept the same url() references as style.
+		return isSafeCSS(attr.Value)
+	}
+	return true
+}
+
+// isSVGScriptAnimation reports whether tok is an animation element that
+// alters a link, which may turn it into a script.
+func isSVGScriptAnimation(tok xml.StartElement) bool {
+	switch strings.ToLower(tok.Name.Local) {
+	case "animate", "set":
+	default:
+		return false
+	}
+	for _, attr := range tok.Attr {
+		if strings.EqualFold(attr.Name.Local, "attributeName") {
+			_, local, _ := strings.Cut(strings.ToLower(attr.Value), ":")
+			if local == "" {
+				local = strings.ToLower(attr.Value)
+			}
+			return local == "href" || local == "src"
+		}
+	}
+	return false
+}
+
+// isLocalSVGReference reports whether a normalized URL refers to a fragment
+// in the same document or an embedded raster image.
+func isLocalSVGReference(value string) bool {
+	if strings.HasPrefix(value, "#") {
+		return true
+	}
+	for _, prefix := range []string{"data:image/png", "data:image/jpeg", "data:image/gif", "data:image/webp"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeCSS reports whether a style sheet has no external references.
+func isSafeCSS(css string) bool {
+	css = normalizeURL(css)
+	if strings.Contains(css, "@import") || strings.Contains(css, "expression(") {
+		return false
+	}
+	for {
+		i := strings.Index(css, "url(")
+		if i < 0 {
+			return true
+		}
+		css = strings.TrimLeft(css[i+len("url("):], `"'`)
+		if !isLocalSVGReference(css) {
+			return false
+		}
+	}
+}
+
+// normalizeURL lowercases s and removes whitespace and control characters
+// that browsers ignore in URLs.
+func normalizeURL(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,151 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	for _, c := range []struct {
+		in     string
+		expect string
+		err    bool
+	}{
+		{
+			in:     `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`,
+			expect: `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"></rect></svg>`,
+		},
+		{
+			in:     `<svg><script>alert(1)</script><g><script><![CDATA[alert(1)]]></script></g></svg>`,
+			expect: `<svg><g></g></svg>`,
+		},
+		{
+			in:     `<svg onload="alert(1)"><rect ONCLICK="alert(1)" fill="red"/></svg>`,
+			expect: `<svg><rect fill="red"></rect></svg>`,
+		},
+		{
+			in:     `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a xlink:href="java&#x09;script:alert(1)"><use href="#a"/></a><image href="https://evil.example.com/"/></svg>`,
+			expect: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a><use href="#a"></use></a><image></image></svg>`,
+		},
+		{
+			in:     `<svg><foreignObject><body xmlns="http://www.w3.org/1999/xhtml"><img src="x"/></body></foreignObject></svg>`,
+			expect: `<svg></svg>`,
+		},
+		{
+			in:     `<svg><a><set attributeName="href" to="javascript:alert(1)"/></a></svg>`,
+			expect: `<svg><a></a></svg>`,
+		},
+		{
+			in:     `<svg><style>@import url(https://evil.example.com/);</style><rect style="fill: url(#g)"/><rect style="fill: url('https://evil.example.com/')"/></svg>`,
+			expect: `<svg><style></style><rect style="fill: url(#g)"></rect><rect></rect></svg>`,
+		},
+		{
+			in:     `<svg><rect fill="url(https://evil.example/x.svg#a)" filter="url(http://evil/f#b)" stroke="url(#s)"/><g mask="url( 'https://evil.example/m' )" clip-path="URL(//evil.example/c)"/></svg>`,
+			expect: `<svg><rect stroke="url(#s)"></rect><g></g></svg>`,
+		},
+		{
+			in:     `<svg><path marker-start="url(https://evil.example/a)" marker-mid="url(#m)" marker-end="url(https://evil.example/b)" cursor="url(https://evil.example/c), auto"/></svg>`,
+			expect: `<svg><path marker-mid="url(#m)"></path></svg>`,
+		},
+		{
+			in:  `<!DOCTYPE svg [<!ENTITY a "aaaaaaaa"><!ENTITY b "&a;&a;&a;&a;">]><svg>&b;</svg>`,
+			err: true,
+		},
+		{
+			in:  `<svg>&unknown;</svg>`,
+			err: true,
+		},
+		{
+			in:  `<html><svg></svg></html>`,
+			err: true,
+		},
+		{
+			in:  `<svg><g></svg>`,
+			err: true,
+		},
+	} {
+		have, err := sanitizeSVG([]byte(c.in))
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected error not occurred", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.in, err)
+			continue
+		}
+		if string(have) != c.expect {
+			t.Errorf("expect %q, got %q", c.expect, have)
+		}
+	}
+}
+
+func TestChame_SanitizeSVG(t *testing.T) {
+	const svg = `<svg onload="alert(1)"><script>alert(1)</script><rect fill="red"/></svg>`
+	for _, c := range []struct {
+		code     int
+		encoding string
+		expect   int
+		body     string
+	}{
+		{code: http.StatusOK, expect: http.StatusOK, body: `<svg><rect fill="red"></rect></svg>`},
+		{code: http.StatusOK, encoding: "identity", expect: http.StatusOK, body: `<svg><rect fill="red"></rect></svg>`},
+		{code: http.StatusOK, encoding: "deflate", expect: http.StatusBadGateway},
+		{code: http.StatusOK, encoding: "br", expect: http.StatusBadGateway},
+		{code: http.StatusNotFound, expect: http.StatusBadGateway},
+	} {
+		chame := &Chame{
+			Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+				w.Header().Set("Content-Type", "image/svg+xml")
+				w.Header().Set("Content-Length", strconv.Itoa(len(svg)))
+				if c.encoding != "" {
+					w.Header().Set("Content-Encoding", c.encoding)
+				}
+				w.WriteHeader(c.code)
+				w.Write([]byte(svg))
+			}),
+			Store:       keyStore,
+			SanitizeSVG: true,
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.svg"), nil)
+		chame.ServeHTTP(w, req)
+		if w.Code != c.expect {
+			t.Errorf("%d %q: expect %d, got %d", c.code, c.encoding, c.expect, w.Code)
+			continue
+		}
+		if strings.Contains(w.Body.String(), "alert") {
+			t.Errorf("%d %q: unsanitized SVG served: %q", c.code, c.encoding, w.Body.String())
+		}
+		if c.expect != http.StatusOK {
+			if have := w.Header().Get("Content-Encoding"); have != "" {
+				t.Errorf("%d %q: Content-Encoding must be removed, got %q", c.code, c.encoding, have)
+			}
+			continue
+		}
+		if have := w.Body.String(); have != c.body {
+			t.Errorf("%d %q: expect %q, got %q", c.code, c.encoding, c.body, have)
+		}
+		if have := w.Header().Get("Content-Length"); have != strconv.Itoa(len(c.body)) {
+			t.Errorf("%d %q: Content-Length %q does not match the body", c.code, c.encoding, have)
+		}
+	}
+}
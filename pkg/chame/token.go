@@ -16,13 +16,18 @@ package chame
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -49,80 +54,322 @@ func toNumericDate(t time.Time) *jwt.NumericDate {
 	return jwt.NewNumericDate(t)
 }
 
+// Claims is a set of claims in signed URLs. It extends Token with private
+// claims that are specific to chame.
+type Claims struct {
+	Token
+	// ContentType restricts Content-Type values of the proxied content in
+	// addition to Chame.ContentType. See SignOption.ContentType.
+	ContentType []string `json:"ctype,omitempty"`
+	// Referer restricts pages that may load the URL in addition to
+	// Chame.RefererPolicies. See SignOption.Referer.
+	Referer *RefererPolicy `json:"ref,omitempty"`
+
+	// KeyID and Algorithm are the "kid" and "alg" header values of the
+	// verified token.
+	KeyID     string `json:"-"`
+	Algorithm string `json:"-"`
+	// KeyName is the name of the key that verified the token. See Key.Name.
+	KeyName string `json:"-"`
+	// KeyIndex is the position of the key that verified the token in the
+	// KeySet returned by Store, where 0 means the primary one.
+	KeyIndex int `json:"-"`
+	// Encrypted reports whether the verified token was encrypted.
+	Encrypted bool `json:"-"`
+	// Raw holds all the claims in the verified token, including unknown
+	// ones.
+	Raw map[string]interface{} `json:"-"`
+}
+
 func EncodeToken(ctx context.Context, store Store, token *Token, kid string) (string, error) {
-	key, err := store.GetSigningKey(token.Issuer, kid)
+	return encodeClaims(ctx, store, &Claims{Token: *token}, kid, "")
+}
+
+// encodeClaims signs token with the key identified by the issuer and kid. If
+// alg is empty, the algorithm of the key or the default for its type is used.
+func encodeClaims(ctx context.Context, store Store, token *Claims, kid string, alg string) (string, error) {
+	signer, err := newTokenSigner(ctx, store, token.Issuer, kid, alg, EncryptNone)
+	if err != nil {
+		return "", err
+	}
+	return signer.sign(token)
+}
+
+// tokenSigner signs claims with keys resolved in advance, so that many tokens
+// can be signed for the same issuer and kid without looking up the Store for
+// each of them. tokenSigner is safe for concurrent use.
+type tokenSigner struct {
+	key    interface{}
+	kid    string
+	mech   jwt.SigningMethod
+	header string
+	// encrypter is nil unless tokens are encrypted.
+	encrypter jose.Encrypter
+}
+
+func newTokenSigner(ctx context.Context, store Store, iss string, kid string, alg string, enc Encryption) (*tokenSigner, error) {
+	found, err := NewStoreContext(store).GetSigningKeyContext(ctx, iss, kid)
+	if err != nil {
+		return nil, fmt.Errorf("chame: failed to retrieve a signing key: %w", err)
+	}
+	k, err := signingKey(found, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	key := k.Material
+	if alg == "" {
+		alg = k.Algorithm
+	}
+
+	mech, err := signingMethod(key, alg)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]interface{}{
+		"typ": "JWT",
+		"alg": mech.Alg(),
+	}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encoded, err := json.Marshal(header)
 	if err != nil {
-		return "", fmt.Errorf("chame: failed to retrieve a signing key: %w", err)
+		return nil, fmt.Errorf("chame: failed to sign a token: %w", err)
 	}
+	signer := &tokenSigner{
+		key:    key,
+		kid:    kid,
+		mech:   mech,
+		header: jwt.EncodeSegment(encoded),
+	}
+	if enc != EncryptNone {
+		signer.encrypter, err = newEncrypter(store, iss, kid, enc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
+
+func (signer *tokenSigner) sign(token *Claims) (string, error) {
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("chame: failed to sign a token: %w", err)
+	}
+	signingString := signer.header + "." + jwt.EncodeSegment(payload)
+	sig, err := signer.mech.Sign(signingString, signer.key)
+	if err != nil {
+		return "", fmt.Errorf("chame: failed to sign a token: %w", err)
+	}
+	signed := signingString + "." + sig
+	if signer.encrypter == nil {
+		return signed, nil
+	}
+
+	obj, err := signer.encrypter.Encrypt([]byte(signed))
+	if err != nil {
+		return "", fmt.Errorf("chame: failed to encrypt a token: %w", err)
+	}
+	return obj.CompactSerialize()
+}
 
-	var mech jwt.SigningMethod
+// signingMethod returns the signing method of alg for key. If alg is empty,
+// the default for the type of key is returned.
+func signingMethod(key interface{}, alg string) (jwt.SigningMethod, error) {
+	var methods []jwt.SigningMethod
 	switch key := key.(type) {
 	default:
-		return "", fmt.Errorf("chame: unsupported key algorithm")
+		return nil, fmt.Errorf("chame: unsupported key algorithm")
 	case []byte:
-		mech = jwt.SigningMethodHS256
+		methods = []jwt.SigningMethod{
+			jwt.SigningMethodHS256,
+			jwt.SigningMethodHS384,
+			jwt.SigningMethodHS512,
+		}
 	case *rsa.PrivateKey:
-		mech = jwt.SigningMethodRS256
+		methods = []jwt.SigningMethod{
+			jwt.SigningMethodRS256,
+			jwt.SigningMethodRS384,
+			jwt.SigningMethodRS512,
+			jwt.SigningMethodPS256,
+			jwt.SigningMethodPS384,
+			jwt.SigningMethodPS512,
+		}
 	case *ecdsa.PrivateKey:
 		switch key.Curve {
 		case elliptic.P256():
-			mech = jwt.SigningMethodES256
+			methods = []jwt.SigningMethod{jwt.SigningMethodES256}
 		case elliptic.P384():
-			mech = jwt.SigningMethodES384
+			methods = []jwt.SigningMethod{jwt.SigningMethodES384}
 		case elliptic.P521():
-			mech = jwt.SigningMethodES512
+			methods = []jwt.SigningMethod{jwt.SigningMethodES512}
 		default:
-			return "", fmt.Errorf("chame: unsupported elliptic curve")
+			return nil, fmt.Errorf("chame: unsupported elliptic curve")
+		}
+	case ed25519.PrivateKey:
+		methods = []jwt.SigningMethod{jwt.SigningMethodEdDSA}
+	case crypto.Signer:
+		var err error
+		methods, err = signerMethods(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if alg == "" {
+		return methods[0], nil
+	}
+	for _, mech := range methods {
+		if mech.Alg() == alg {
+			return mech, nil
 		}
 	}
+	return nil, fmt.Errorf("chame: algorithm %q is not supported for the key", alg)
+}
 
-	jwtobj := jwt.NewWithClaims(mech, token)
-	if kid != "" {
-		jwtobj.Header["kid"] = kid
+// SupportedAlgorithms is a list of JWS algorithms accepted in signed URLs.
+// SupportedAlgorithms is provided for only documentation purpose and
+// modifying it has no effect.
+var SupportedAlgorithms = []string{
+	"HS256", "HS384", "HS512",
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// parser parses tokens without verification, which is done by
+// verifySignature instead to try multiple keys.
+var parser = jwt.NewParser(jwt.WithoutClaimsValidation())
+
+// DecodeToken verifies tokenString and returns the URL in it. If store
+// implements RevocationChecker, DecodeToken also verifies that the token is
+// not revoked. If store implements KeyObserver, it is notified of the key
+// that verified the token.
+func DecodeToken(ctx context.Context, store Store, tokenString string) (string, error) {
+	claims, err := DecodeClaimsWithOption(ctx, store, tokenString, DecodeOption{})
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+// DecodeClaims verifies tokenString like DecodeToken, and returns the
+// verified claims along with the key ID and algorithm used to sign it.
+func DecodeClaims(ctx context.Context, store Store, tokenString string) (*Claims, error) {
+	return DecodeClaimsWithOption(ctx, store, tokenString, DecodeOption{})
+}
+
+// DecodeOption customizes verification by DecodeClaimsWithOption.
+type DecodeOption struct {
+	// Audience, if not empty, must be contained in the "aud" claim, ignoring
+	// case. Otherwise the error is ErrTokenAudience.
+	Audience string
+}
+
+// DecodeClaimsWithOption is like DecodeClaims, but also verifies the token
+// as specified by opt.
+func DecodeClaimsWithOption(ctx context.Context, store Store, tokenString string, opt DecodeOption) (*Claims, error) {
+	opts := decodeOptions{audience: opt.Audience}
+	opts.revocation, _ = store.(RevocationChecker)
+	opts.keyObserver, _ = store.(KeyObserver)
+	return decodeToken(ctx, store, tokenString, &opts)
+}
+
+// decodeOptions customizes verification of tokens.
+type decodeOptions struct {
+	// checkAlg reports whether a JWS algorithm is accepted for an issuer. If
+	// nil, all the supported algorithms are accepted.
+	checkAlg func(iss, alg string) bool
+	// revocation is consulted after the signature is verified if not nil.
+	revocation RevocationChecker
+	// issuerAliases maps issuer aliases in compact tokens to issuers.
+	issuerAliases map[string]string
+	// audience must be in the "aud" claim if not empty.
+	audience string
+	// keyObserver is notified of the key that verified the token if not
+	// nil.
+	keyObserver KeyObserver
+}
+
+// decodeToken verifies and decodes a signed or encrypted token.
+func decodeToken(ctx context.Context, store Store, tokenString string, opts *decodeOptions) (*Claims, error) {
+	if opts == nil {
+		opts = &decodeOptions{}
+	}
+	var (
+		claims *Claims
+		err    error
+	)
+	switch {
+	case isCompactToken(tokenString):
+		claims, err = decodeCompactToken(ctx, store, tokenString, opts.checkAlg, opts.issuerAliases)
+	case isEncryptedToken(tokenString):
+		claims, err = decodeEncryptedToken(ctx, store, tokenString, opts.checkAlg)
+	default:
+		claims, err = decodeSignedToken(ctx, store, tokenString, opts.checkAlg)
 	}
-	signed, err := jwtobj.SignedString(key)
 	if err != nil {
-		return "", fmt.Errorf("chame: failed to sign a token: %w", err)
+		return nil, err
+	}
+	if opts.keyObserver != nil {
+		opts.keyObserver.ObserveKey(ctx, claims)
 	}
-	return signed, nil
-}
-
-var parserPool = sync.Pool{
-	New: func() interface{} {
-		return jwt.NewParser(
-			jwt.WithValidMethods([]string{
-				jwt.SigningMethodHS256.Name,
-				jwt.SigningMethodHS384.Name,
-				jwt.SigningMethodHS512.Name,
-				jwt.SigningMethodRS256.Name,
-				jwt.SigningMethodES384.Name,
-				jwt.SigningMethodRS512.Name,
-				jwt.SigningMethodES256.Name,
-				jwt.SigningMethodES384.Name,
-				jwt.SigningMethodES512.Name,
-			}),
-			jwt.WithoutClaimsValidation())
-	},
-}
-
-func DecodeToken(_ context.Context, store Store, tokenString string) (string, error) {
-	parser := parserPool.Get().(*jwt.Parser)
-	defer parserPool.Put(parser)
-
-	claims := Token{}
-	_, err := parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
-		kid, _ := token.Header["kid"].(string)
-		return store.GetVerifyingKey(claims.Issuer, kid)
-	})
+	if opts.audience != "" {
+		if err := validateAudience(&claims.Token, opts.audience); err != nil {
+			return nil, classifyJWTError(err)
+		}
+	}
+	if opts.revocation != nil {
+		if err := checkRevocation(ctx, opts.revocation, claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func decodeEncryptedToken(ctx context.Context, store Store, tokenString string, checkAlg func(iss, alg string) bool) (*Claims, error) {
+	signed, iss, err := decryptToken(ctx, store, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := decodeSignedToken(ctx, store, signed, checkAlg)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != iss {
+		return nil, &tokenError{ErrTokenMalformed, errors.New("chame: failed to decode encrypted token: issuer mismatch")}
+	}
+	claims.Encrypted = true
+	return claims, nil
+}
+
+func decodeSignedToken(ctx context.Context, store Store, tokenString string, checkAlg func(iss, alg string) bool) (*Claims, error) {
+	claims := Claims{}
+	token, parts, err := parser.ParseUnverified(tokenString, &claims)
 	if err != nil {
-		return "", fmt.Errorf("chame: failed to decode signed token: %w", err)
+		return nil, fmt.Errorf("chame: failed to decode signed token: %w", classifyJWTError(err))
+	}
+	alg := token.Method.Alg()
+	if !isSupportedAlgorithm(alg) {
+		return nil, &tokenError{ErrUnsupportedAlgorithm, fmt.Errorf("chame: algorithm %q is not supported", alg)}
 	}
+	if checkAlg != nil && !checkAlg(claims.Issuer, alg) {
+		return nil, &tokenError{ErrUnsupportedAlgorithm, fmt.Errorf("chame: algorithm %q is not accepted", alg)}
+	}
+	claims.KeyID, _ = token.Header["kid"].(string)
+	claims.Algorithm = alg
+	if err := verifySignature(ctx, store, &claims, parts[0]+"."+parts[1], parts[2]); err != nil {
+		return nil, fmt.Errorf("chame: failed to decode signed token: %w", err)
+	}
+	// NOTE(yosida95): the payload is well-formed as it has been parsed.
+	payload, _ := jwt.DecodeSegment(parts[1])
+	json.Unmarshal(payload, &claims.Raw)
 
 	now := time.Now()
-	if err := validateClaims(&claims, now); err != nil {
-		return "", fmt.Errorf("chame: failed to decode signed token: %w", err)
+	if err := validateClaims(&claims.Token, now); err != nil {
+		return nil, fmt.Errorf("chame: failed to decode signed token: %w", classifyJWTError(err))
 	}
-	return claims.Subject, nil
+	return &claims, nil
 }
 
 func validateClaims(claims *Token, now time.Time) error {
@@ -144,3 +391,17 @@ func validateClaims(claims *Token, now time.Time) error {
 	}
 	return &jwtErr
 }
+
+// validateAudience verifies that the "aud" claim contains aud. They are
+// compared case-insensitively since audiences are usually host names.
+func validateAudience(claims *Token, aud string) error {
+	for _, v := range claims.Audience {
+		if strings.EqualFold(v, aud) {
+			return nil
+		}
+	}
+	return &jwt.ValidationError{
+		Inner:  fmt.Errorf("chame: token is not intended for %q", aud),
+		Errors: jwt.ValidationErrorAudience,
+	}
+}
@@ -16,7 +16,15 @@ package chame
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -130,3 +138,251 @@ func TestValidateClaims(t *testing.T) {
 		}
 	}
 }
+
+type keyPairStore struct {
+	signing   interface{}
+	verifying interface{}
+}
+
+func (store *keyPairStore) GetSigningKey(iss string, kid string) (interface{}, error) {
+	return store.signing, nil
+}
+
+func (store *keyPairStore) GetVerifyingKey(iss string, kid string) (interface{}, error) {
+	return store.verifying, nil
+}
+
+func TestEdDSA(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("failed to parse PEM: %v", err)
+	}
+
+	store := &keyPairStore{signing: parsed, verifying: pub}
+	const url = "https://example.com/foo.png"
+	encoded, err := EncodeToken(context.Background(), store, &Token{
+		Issuer:  defaultIss,
+		Subject: url,
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "eyJhbGciOiJFZERTQSIs") { // {"alg":"EdDSA",
+		t.Errorf("unexpected header: %q", encoded)
+	}
+	decoded, err := DecodeToken(context.Background(), store, encoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded != url {
+		t.Errorf("expect %q, got %q", url, decoded)
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := []byte("dummysecret")
+
+	for _, c := range []struct {
+		signing   interface{}
+		verifying interface{}
+		opt       string
+		alg       string
+		accepted  map[string][]string
+		err       bool
+	}{
+		{signing: rsaKey, verifying: &rsaKey.PublicKey, alg: "RS256"},
+		{signing: rsaKey, verifying: &rsaKey.PublicKey, opt: "RS384", alg: "RS384"},
+		{signing: rsaKey, verifying: &rsaKey.PublicKey, opt: "PS512", alg: "PS512"},
+		{signing: hmacKey, verifying: hmacKey, opt: "HS512", alg: "HS512"},
+		{
+			signing:   &Key{Material: rsaKey, Algorithm: "PS256"},
+			verifying: &Key{Material: &rsaKey.PublicKey, Algorithm: "PS256"},
+			alg:       "PS256",
+		},
+		{
+			signing:   rsaKey,
+			verifying: &Key{Material: &rsaKey.PublicKey, Algorithm: "PS256"},
+			alg:       "RS256",
+			err:       true,
+		},
+		{
+			signing:   hmacKey,
+			verifying: hmacKey,
+			alg:       "HS256",
+			accepted:  map[string][]string{defaultIss: {"HS512"}},
+			err:       true,
+		},
+		{
+			signing:   hmacKey,
+			verifying: hmacKey,
+			alg:       "HS256",
+			accepted:  map[string][]string{"https://other.example.com": {"HS512"}},
+		},
+	} {
+		store := &keyPairStore{signing: c.signing, verifying: c.verifying}
+		encoded, err := encodeClaims(context.Background(), store, &Claims{Token: Token{
+			Issuer:  defaultIss,
+			Subject: "https://example.com/foo.png",
+		}}, "", c.opt)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.alg, err)
+			continue
+		}
+		var header struct {
+			Alg string `json:"alg"`
+		}
+		segment, _, _ := strings.Cut(encoded, ".")
+		if b, err := base64.RawURLEncoding.DecodeString(segment); err != nil || json.Unmarshal(b, &header) != nil {
+			t.Errorf("%s: malformed header: %q", c.alg, segment)
+		} else if header.Alg != c.alg {
+			t.Errorf("expect %q, got %q", c.alg, header.Alg)
+		}
+
+		chame := &Chame{Algorithms: c.accepted}
+		_, err = decodeToken(context.Background(), store, encoded, &decodeOptions{
+			checkAlg: chame.acceptsAlgorithm,
+		})
+		if c.err != (err != nil) {
+			t.Errorf("%s: unexpected result: %v", c.alg, err)
+		}
+	}
+
+	if _, err := encodeClaims(context.Background(), &keyPairStore{signing: hmacKey}, &Claims{}, "", "RS256"); err == nil {
+		t.Errorf("RS256 must not be used with HMAC keys")
+	}
+}
+
+func TestDecodeClaims(t *testing.T) {
+	encoded, err := encodeClaims(context.Background(), store, &Claims{
+		Token: Token{
+			Issuer:  defaultIss,
+			Subject: "https://example.com/foo.png",
+		},
+		ContentType: []string{"image/png"},
+	}, defaultKid, "HS384")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	claims, err := DecodeClaims(context.Background(), store, encoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claims.Issuer != defaultIss || claims.Subject != "https://example.com/foo.png" {
+		t.Errorf("unexpected claims: %+v", claims.Token)
+	}
+	if claims.KeyID != defaultKid {
+		t.Errorf("expected kid %q, have %q", defaultKid, claims.KeyID)
+	}
+	if claims.Algorithm != "HS384" {
+		t.Errorf("expected alg %q, have %q", "HS384", claims.Algorithm)
+	}
+	if claims.Encrypted {
+		t.Errorf("signed token must not be reported as encrypted")
+	}
+	if ctype, _ := claims.Raw["ctype"].([]interface{}); len(ctype) != 1 || ctype[0] != "image/png" {
+		t.Errorf("unexpected raw claims: %v", claims.Raw)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	sign := func(tok Token, alg string) string {
+		tok.Issuer = defaultIss
+		tok.Subject = "https://example.com/foo.png"
+		encoded, err := encodeClaims(context.Background(), store, &Claims{Token: tok}, defaultKid, alg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return encoded
+	}
+	rawToken := func(header string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+defaultIss+`","sub":"https://example.com/foo.png"}`)) + "."
+	}
+	valid := sign(Token{}, "")
+	past := jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	future := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	for i, c := range []struct {
+		token string
+		store Store
+		opts  *decodeOptions
+		err   error
+	}{
+		{token: "malformed", err: ErrTokenMalformed},
+		{token: "a.b.c.d.e", store: &encstore{mockstore: store}, err: ErrTokenMalformed},
+		{token: sign(Token{ExpiresAt: past}, ""), err: ErrTokenExpired},
+		{token: sign(Token{NotBefore: future}, ""), err: ErrTokenNotYetValid},
+		{token: sign(Token{IssuedAt: future}, ""), err: ErrTokenNotYetValid},
+		{
+			token: valid,
+			store: &mockstore{iss: defaultIss, kid: "other", key: store.key},
+			err:   ErrUnknownKey,
+		},
+		{token: valid[:len(valid)-4] + "AAAA", err: ErrBadSignature},
+		{token: rawToken(`{"alg":"none"}`), err: ErrUnsupportedAlgorithm},
+		{token: rawToken(`{"alg":"XX999"}`), err: ErrUnsupportedAlgorithm},
+		{
+			token: sign(Token{}, "HS512"),
+			opts: &decodeOptions{checkAlg: func(iss, alg string) bool {
+				return alg == "HS256"
+			}},
+			err: ErrUnsupportedAlgorithm,
+		},
+		{
+			token: sign(Token{Audience: jwt.ClaimStrings{"a.example.com"}}, ""),
+			opts:  &decodeOptions{audience: "b.example.com"},
+			err:   ErrTokenAudience,
+		},
+		{token: valid, opts: &decodeOptions{audience: "b.example.com"}, err: ErrTokenAudience},
+	} {
+		s := c.store
+		if s == nil {
+			s = store
+		}
+		_, err := decodeToken(context.Background(), s, c.token, c.opts)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%d: expected %v, have %v", i, c.err, err)
+		}
+	}
+}
+
+func TestDecodeClaimsWithOption(t *testing.T) {
+	encoded, err := encodeClaims(context.Background(), store, &Claims{Token: Token{
+		Issuer:   defaultIss,
+		Subject:  "https://example.com/foo.png",
+		Audience: jwt.ClaimStrings{"a.example.com", "b.example.com"},
+	}}, defaultKid, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range []struct {
+		aud string
+		err error
+	}{
+		{aud: ""},
+		{aud: "a.example.com"},
+		{aud: "b.example.com"},
+		{aud: "B.Example.COM"},
+		{aud: "c.example.com", err: ErrTokenAudience},
+	} {
+		claims, err := DecodeClaimsWithOption(context.Background(), store, encoded, DecodeOption{Audience: c.aud})
+		if !errors.Is(err, c.err) {
+			t.Errorf("%q: expected %v, have %v", c.aud, c.err, err)
+			continue
+		}
+		if err == nil && claims.Subject != "https://example.com/foo.png" {
+			t.Errorf("%q: unexpected subject %q", c.aud, claims.Subject)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Transcoding describes how proxied images of a Content-Type are re-encoded.
+type Transcoding struct {
+	// To is a list of Content-Type values to re-encode images into, in
+	// order of preference. The first one that the client accepts is chosen.
+	// Listing the source Content-Type stops the search, which means the
+	// original is preferred to the rest. Only image/gif, image/jpeg and
+	// image/png are encoded by chame itself; other targets, such as
+	// image/webp, need an entry in Chame.ImageEncoder. See Chame.Validate.
+	// Targets not allowed for the URL by its Content-Type restriction are
+	// skipped.
+	To []string
+	// Quality is passed to ImageEncoder as the encoding quality in the range
+	// of 1 to 100. Zero means the default of each encoder.
+	Quality int
+}
+
+// ImageEncoder encodes m into a specific image format. quality is in the
+// range of 0 to 100, where 0 means the default of the encoder. Encoders of
+// lossless formats may ignore it.
+type ImageEncoder interface {
+	Encode(w io.Writer, m image.Image, quality int) error
+}
+
+type ImageEncoderFunc func(w io.Writer, m image.Image, quality int) error
+
+func (fn ImageEncoderFunc) Encode(w io.Writer, m image.Image, quality int) error {
+	return fn(w, m, quality)
+}
+
+var builtinImageEncoder = map[string]ImageEncoder{
+	"image/gif": ImageEncoderFunc(func(w io.Writer, m image.Image, _ int) error {
+		return gif.Encode(w, m, nil)
+	}),
+	"image/jpeg": ImageEncoderFunc(func(w io.Writer, m image.Image, quality int) error {
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, m, &jpeg.Options{Quality: quality})
+	}),
+	"image/png": ImageEncoderFunc(func(w io.Writer, m image.Image, _ int) error {
+		return png.Encode(w, m)
+	}),
+}
+
+// universalImageType is a set of Content-Type values that every client is
+// assumed to support. Only these are matched by wildcards in Accept.
+var universalImageType = map[string]bool{
+	"image/gif":  true,
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// maxTranscodePixels is the maximum number of pixels of an image to be
+// decoded for transcoding.
+const maxTranscodePixels = 1 << 26
+
+func (chame *Chame) imageEncoder(ctype string) ImageEncoder {
+	if enc, ok := chame.ImageEncoder[ctype]; ok {
+		return enc
+	}
+	return builtinImageEncoder[ctype]
+}
+
+// validateTranscode reports an error if any target of Transcode lacks an
+// ImageEncoder.
+func (chame *Chame) validateTranscode() error {
+	for from, tc := range chame.Transcode {
+		for _, to := range tc.To {
+			to = strings.ToLower(to)
+			if to != strings.ToLower(from) && chame.imageEncoder(to) == nil {
+				return fmt.Errorf("chame: no ImageEncoder to transcode %q into %q", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// transcodeFilter returns a filter that re-encodes images of ctype into the
+// first target that the client accepts and allow reports true for.
+func (chame *Chame) transcodeFilter(hdr http.Header, ctype string, reqHdr http.Header, allow func(string) bool) (bodyFilter, bool) {
+	tc, ok := chame.Transcode[ctype]
+	if !ok {
+		return bodyFilter{}, false
+	}
+	hdr.Add("Vary", "Accept")
+
+	accept := reqHdr.Values("Accept")
+	for _, to := range tc.To {
+		to = strings.ToLower(to)
+		if to == ctype {
+			break
+		}
+		enc := chame.imageEncoder(to)
+		if enc == nil || !allow(to) || !acceptsType(accept, to, universalImageType[to]) {
+			continue
+		}
+		return bodyFilter{
+			apply: func(hdr http.Header, body []byte) ([]byte, error) {
+				return transcodeImage(hdr, body, to, enc, tc.Quality), nil
+			},
+		}, true
+	}
+	return bodyFilter{}, false
+}
+
+// transcodeImage re-encodes body into ctype. It returns body as is if body
+// cannot be transcoded or the result is not smaller than the original.
+func transcodeImage(hdr http.Header, body []byte, ctype string, enc ImageEncoder, quality int) []byte {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("chame: failed to decode image to transcode: %v", err)
+		return body
+	}
+	if cfg.Width*cfg.Height > maxTranscodePixels || isAnimatedImage(format, body) {
+		return body
+	}
+	m, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("chame: failed to decode image to transcode: %v", err)
+		return body
+	}
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, m, quality); err != nil {
+		log.Printf("chame: failed to encode image into %q: %v", ctype, err)
+		return body
+	}
+	if buf.Len() >= len(body) {
+		return body
+	}
+	hdr.Set(headerKeyContentType, ctype)
+	// NOTE(yosida95): the validator of the original does not identify the
+	// transcoded representation.
+	hdr.Del("Etag")
+	return buf.Bytes()
+}
+
+// isAnimatedImage reports whether body has more than one frame, which would
+// be lost by transcoding.
+func isAnimatedImage(format string, body []byte) bool {
+	switch format {
+	case "gif":
+		n, err := countGIFFrames(bufio.NewReader(bytes.NewReader(body)), 1)
+		return err != nil || n > 1
+	case "png":
+		n, err := countPNGFrames(bufio.NewReader(bytes.NewReader(body)), 1)
+		return err != nil || n > 1
+	}
+	return false
+}
+
+// acceptsType reports whether the values of the Accept header allow ctype.
+// The most specific media range matching ctype takes precedence, and wildcards
+// are taken into account only if wildcard is true.
+func acceptsType(accept []string, ctype string, wildcard bool) bool {
+	major, _, _ := strings.Cut(ctype, "/")
+	spec, q := 0, 0.0
+	for _, v := range accept {
+		for _, rng := range strings.Split(v, ",") {
+			mt, params, err := mime.ParseMediaType(rng)
+			if err != nil {
+				continue
+			}
+			var s int
+			switch {
+			case mt == ctype:
+				s = 3
+			case wildcard && mt == major+"/*":
+				s = 2
+			case wildcard && mt == "*/*":
+				s = 1
+			default:
+				continue
+			}
+			if s < spec {
+				continue
+			}
+			spec, q = s, 1
+			if v, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return q > 0
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+func TestAcceptsType(t *testing.T) {
+	for _, c := range []struct {
+		accept   []string
+		ctype    string
+		wildcard bool
+		expect   bool
+	}{
+		{
+			accept: nil,
+			ctype:  "image/webp",
+			expect: false,
+		},
+		{
+			accept: []string{"image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"},
+			ctype:  "image/webp",
+			expect: true,
+		},
+		{
+			accept: []string{"image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5"},
+			ctype:  "image/webp",
+			expect: false,
+		},
+		{
+			accept:   []string{"image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5"},
+			ctype:    "image/gif",
+			wildcard: true,
+			expect:   true,
+		},
+		{
+			accept:   []string{"image/*", "image/png;q=0"},
+			ctype:    "image/png",
+			wildcard: true,
+			expect:   false,
+		},
+		{
+			accept:   []string{"*/*;q=0.1"},
+			ctype:    "image/png",
+			wildcard: true,
+			expect:   true,
+		},
+	} {
+		if have := acceptsType(c.accept, c.ctype, c.wildcard); have != c.expect {
+			t.Errorf("%q, %q: expect %t, got %t", c.accept, c.ctype, c.expect, have)
+		}
+	}
+}
+
+func TestChame_Transcode(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range m.Pix {
+		m.Pix[i] = 0xff
+	}
+	var bmpImage, pngImage bytes.Buffer
+	if err := bmp.Encode(&bmpImage, m); err != nil {
+		t.Fatal(err)
+	}
+	tiny := image.NewGray(image.Rect(0, 0, 1, 1))
+	tiny.Set(0, 0, color.White)
+	if err := png.Encode(&pngImage, tiny); err != nil {
+		t.Fatal(err)
+	}
+
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			switch req.URL.Path {
+			case "/cat.bmp":
+				w.Header().Set("Content-Type", "image/bmp")
+				w.Header().Set("Etag", `"bmp"`)
+				w.Write(bmpImage.Bytes())
+			case "/cat.png":
+				w.Header().Set("Content-Type", "image/png")
+				w.Write(pngImage.Bytes())
+			}
+		}),
+		Store: keyStore,
+		Transcode: map[string]Transcoding{
+			"image/bmp": {To: []string{"image/png"}},
+			"image/png": {To: []string{"image/jpeg"}},
+		},
+	}
+	for _, c := range []struct {
+		url      string
+		restrict []string
+		accept   string
+		ctype    string
+		etag     string
+	}{
+		{
+			url:    "https://example.net/cat.bmp",
+			accept: "image/*",
+			ctype:  "image/png",
+		},
+		{
+			url:    "https://example.net/cat.bmp",
+			accept: "image/bmp",
+			ctype:  "image/bmp",
+			etag:   `"bmp"`,
+		},
+		{
+			// the URL is restricted to the original type
+			url:      "https://example.net/cat.bmp",
+			restrict: []string{"image/bmp"},
+			accept:   "image/*",
+			ctype:    "image/bmp",
+			etag:     `"bmp"`,
+		},
+		{
+			// the JPEG is larger than the PNG
+			url:    "https://example.net/cat.png",
+			accept: "image/jpeg",
+			ctype:  "image/png",
+		},
+	} {
+		signed, err := encodeClaims(context.Background(), keyStore, &Claims{
+			Token: Token{
+				Issuer:  "https://chame.example.net",
+				Subject: c.url,
+			},
+			ContentType: c.restrict,
+		}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, proxyPrefix+signed, nil)
+		req.Header.Set("Accept", c.accept)
+		chame.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expect %d, got %d", http.StatusOK, w.Code)
+			continue
+		}
+		if have := w.Header().Get("Content-Type"); have != c.ctype {
+			t.Errorf("expect %q, got %q", c.ctype, have)
+		}
+		if have := w.Header().Get("Etag"); have != c.etag {
+			t.Errorf("expect %q, got %q", c.etag, have)
+		}
+		if have := w.Header().Get("Vary"); have != "Accept" {
+			t.Errorf("expect %q, got %q", "Accept", have)
+		}
+		_, format, err := image.DecodeConfig(w.Body)
+		if err != nil || "image/"+format != c.ctype {
+			t.Errorf("unexpected body: %q, %v", format, err)
+		}
+	}
+}
+
+func TestIsAnimatedImage(t *testing.T) {
+	ihdr := []byte("\x00\x00\x00\x01\x00\x00\x00\x01\x08\x00\x00\x00\x00")
+	pngImage := func(chunks ...[]byte) []byte {
+		b := []byte("\x89PNG\r\n\x1a\n")
+		b = append(b, pngChunk("IHDR", ihdr)...)
+		for _, c := range chunks {
+			b = append(b, c...)
+		}
+		b = append(b, pngChunk("IDAT", nil)...)
+		return append(b, pngChunk("IEND", nil)...)
+	}
+	gifImage := func(n int) []byte {
+		frame := image.NewPaletted(image.Rect(0, 0, 1, 1), palette.Plan9)
+		g := &gif.GIF{}
+		for i := 0; i < n; i++ {
+			g.Image = append(g.Image, frame)
+			g.Delay = append(g.Delay, 0)
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, c := range []struct {
+		format string
+		body   []byte
+		expect bool
+	}{
+		{format: "png", body: pngImage(), expect: false},
+		{format: "png", body: pngImage(pngChunk("tEXt", []byte("Comment\x00acTL"))), expect: false},
+		{format: "png", body: pngImage(pngChunk("acTL", []byte("\x00\x00\x00\x03\x00\x00\x00\x00"))), expect: true},
+		{format: "gif", body: gifImage(1), expect: false},
+		{format: "gif", body: gifImage(2), expect: true},
+	} {
+		if have := isAnimatedImage(c.format, c.body); have != c.expect {
+			t.Errorf("%s: expect %t, got %t", c.format, c.expect, have)
+		}
+	}
+}
+
+func TestChame_Validate(t *testing.T) {
+	chame := &Chame{
+		Transcode: map[string]Transcoding{
+			"image/png": {To: []string{"image/webp", "image/png"}},
+		},
+	}
+	if err := chame.Validate(); err == nil {
+		t.Error("expect an error for image/webp")
+	}
+	chame.ImageEncoder = map[string]ImageEncoder{
+		"image/webp": ImageEncoderFunc(func(io.Writer, image.Image, int) error { return nil }),
+	}
+	if err := chame.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// isSupportedAlgorithm reports whether alg is one of SupportedAlgorithms.
+func isSupportedAlgorithm(alg string) bool {
+	_, ok := compactAlgorithmID(alg)
+	return ok
+}
+
+// candidateKeys returns keys in key returned by Store, which may be bare key
+// material, *Key or KeySet.
+func candidateKeys(key interface{}) []*Key {
+	switch key := key.(type) {
+	case KeySet:
+		return key
+	case *Key:
+		return []*Key{key}
+	}
+	return []*Key{{Material: key}}
+}
+
+// signingKey selects a key to sign with from key returned by Store.
+func signingKey(key interface{}, now time.Time) (*Key, error) {
+	for _, k := range candidateKeys(key) {
+		if k.validAt(now) {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("chame: no signing key is valid at the moment")
+}
+
+// KeyObserver is notified of keys that verify tokens, so that rotation of keys
+// can be monitored, e.g. a retired key that no longer matches or a new primary
+// key that starts to match.
+type KeyObserver interface {
+	// ObserveKey is called with the claims of each token verified, whose
+	// Issuer, KeyID, Algorithm, KeyName and KeyIndex identify the key. It
+	// is called before the audience and revocation are checked, and must
+	// not modify claims.
+	ObserveKey(ctx context.Context, claims *Claims)
+}
+
+// verifySignature verifies sig of signingString with the verifying keys for
+// the issuer and the key ID of claims, and sets the name and the position of
+// the matched key to claims. Keys are tried in order until one matches.
+func verifySignature(ctx context.Context, store Store, claims *Claims, signingString string, sig string) error {
+	key, err := NewStoreContext(store).GetVerifyingKeyContext(ctx, claims.Issuer, claims.KeyID)
+	if err != nil {
+		return keyLookupError(err)
+	}
+	mech := jwt.GetSigningMethod(claims.Algorithm)
+
+	now := time.Now()
+	keys := candidateKeys(key)
+	var (
+		matched    *Key
+		matchedIdx int
+		valid      int
+		tried      int
+		verifyErr  error
+	)
+	for i, k := range keys {
+		if !k.validAt(now) {
+			continue
+		}
+		valid++
+		if k.Algorithm != "" && k.Algorithm != claims.Algorithm {
+			continue
+		}
+		tried++
+		if err := mech.Verify(signingString, sig, k.Material); err != nil {
+			verifyErr = err
+			continue
+		}
+		matched, matchedIdx = k, i
+		break
+	}
+	switch {
+	case matched != nil:
+	case valid == 0:
+		return &tokenError{ErrUnknownKey, errors.New("chame: no key is valid at the moment")}
+	case tried == 0:
+		return &tokenError{ErrUnsupportedAlgorithm, fmt.Errorf("chame: algorithm %q is not allowed for the keys", claims.Algorithm)}
+	default:
+		return &tokenError{ErrBadSignature, fmt.Errorf("chame: failed to verify a token: %w", verifyErr)}
+	}
+
+	claims.KeyName, claims.KeyIndex = matched.Name, matchedIdx
+	if matchedIdx > 0 {
+		// NOTE(yosida95): the token is signed with a key other than the
+		// primary one, which tells that the key is still in use.
+		log.Printf("chame: verified a token of %q (kid %q) with key %q, #%d of %d candidates",
+			claims.Issuer, claims.KeyID, matched.Name, matchedIdx+1, len(keys))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chame
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeySet(t *testing.T) {
+	now := time.Now()
+	oldKey := &Key{Material: []byte("oldsecret"), Name: "old", NotAfter: now.Add(time.Hour)}
+	newKey := &Key{Material: []byte("newsecret"), Name: "new", NotBefore: now.Add(-time.Hour)}
+	sign := func(key interface{}) string {
+		token, err := encodeClaims(context.Background(), &keyPairStore{signing: key}, &Claims{Token: Token{
+			Issuer:  defaultIss,
+			Subject: "https://example.com/foo.png",
+		}}, "", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return token
+	}
+
+	for i, c := range []struct {
+		token     string
+		verifying interface{}
+		name      string
+		err       error
+	}{
+		{token: sign(newKey), verifying: KeySet{newKey, oldKey}, name: "new"},
+		{token: sign(oldKey), verifying: KeySet{newKey, oldKey}, name: "old"},
+		{token: sign([]byte("unknown")), verifying: KeySet{newKey, oldKey}, err: ErrBadSignature},
+		{
+			token:     sign(oldKey),
+			verifying: KeySet{newKey, &Key{Material: oldKey.Material, NotAfter: now.Add(-time.Second)}},
+			err:       ErrBadSignature,
+		},
+		{
+			token:     sign(newKey),
+			verifying: KeySet{&Key{Material: newKey.Material, NotBefore: now.Add(time.Hour)}},
+			err:       ErrUnknownKey,
+		},
+		{
+			token:     sign(newKey),
+			verifying: KeySet{&Key{Material: newKey.Material, Algorithm: "HS512"}},
+			err:       ErrUnsupportedAlgorithm,
+		},
+		{token: sign(newKey), verifying: newKey.Material},
+	} {
+		claims, err := decodeToken(context.Background(), &keyPairStore{verifying: c.verifying}, c.token, nil)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("%d: expected %v, have %v", i, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		if claims.KeyName != c.name {
+			t.Errorf("%d: expected key %q, have %q", i, c.name, claims.KeyName)
+		}
+	}
+
+	// the first key valid at the moment is used to sign
+	token := sign(KeySet{{Material: []byte("expired"), NotAfter: now.Add(-time.Second)}, newKey, oldKey})
+	claims, err := decodeToken(context.Background(), &keyPairStore{verifying: KeySet{oldKey, newKey}}, token, nil)
+	if err != nil || claims.KeyName != "new" {
+		t.Errorf("expected to be signed with new key: %v", err)
+	}
+	if _, err := encodeClaims(context.Background(), &keyPairStore{signing: KeySet{}}, &Claims{}, "", ""); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}
+
+// observedStore is a Store that records keys notified as KeyObserver.
+type observedStore struct {
+	keyPairStore
+	observed []string
+}
+
+func (s *observedStore) ObserveKey(_ context.Context, claims *Claims) {
+	s.observed = append(s.observed, fmt.Sprintf("%s#%d", claims.KeyName, claims.KeyIndex))
+}
+
+func TestKeyObserver(t *testing.T) {
+	oldKey := &Key{Material: []byte("oldsecret"), Name: "old"}
+	newKey := &Key{Material: []byte("newsecret"), Name: "new"}
+	store := &observedStore{keyPairStore: keyPairStore{verifying: KeySet{newKey, oldKey}}}
+	for _, key := range []*Key{newKey, oldKey, {Material: []byte("unknown")}} {
+		store.signing = key
+		token, err := encodeClaims(context.Background(), store, &Claims{Token: Token{
+			Issuer:  defaultIss,
+			Subject: "https://example.com/foo.png",
+		}}, "", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		DecodeToken(context.Background(), store, token)
+	}
+	if expect := []string{"new#0", "old#1"}; !reflect.DeepEqual(store.observed, expect) {
+		t.Errorf("expect %v, got %v", expect, store.observed)
+	}
+
+	// KeyIndex is the position in the KeySet even if the primary key is
+	// skipped for its algorithm.
+	store.observed = nil
+	store.verifying = KeySet{{Material: []byte("other"), Name: "other", Algorithm: "HS384"}, oldKey}
+	store.signing = oldKey
+	token, err := encodeClaims(context.Background(), store, &Claims{Token: Token{
+		Issuer:  defaultIss,
+		Subject: "https://example.com/foo.png",
+	}}, "", "HS256")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := DecodeToken(context.Background(), store, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := []string{"old#1"}; !reflect.DeepEqual(store.observed, expect) {
+		t.Errorf("expect %v, got %v", expect, store.observed)
+	}
+
+	// Chame.KeyObserver takes precedence over Store.
+	observer := &observedStore{}
+	chame := &Chame{
+		Proxy: proxyfunc(func(w http.ResponseWriter, req *ProxyRequest) {
+			w.Header().Set("Content-Type", "image/png")
+		}),
+		Store:       keyStore,
+		KeyObserver: observer,
+	}
+	w := httptest.NewRecorder()
+	chame.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signedPath(t, "https://example.net/cat.png"), nil))
+	if expect := []string{"#0"}; !reflect.DeepEqual(observer.observed, expect) {
+		t.Errorf("expect %v, got %v", expect, observer.observed)
+	}
+}
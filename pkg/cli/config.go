@@ -15,13 +15,27 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/yosida95/chame/pkg/chame"
+	"github.com/yosida95/chame/pkg/keydir"
 	"github.com/yosida95/chame/pkg/memstore"
 )
 
 type Config struct {
 	Issuer string
 	Secret string
+	// KeyFile is a path to a PEM-encoded private key of RSA, ECDSA or
+	// Ed25519. If set, it is used in place of Secret.
+	KeyFile string
+	// KeyDir is a path to a directory of keys laid out as described in
+	// package keydir. If set, it is used in place of KeyFile and Secret.
+	KeyDir string
+	// KeyDirPollInterval is how often KeyDir is checked for changes. Zero
+	// disables polling.
+	KeyDirPollInterval time.Duration
 
 	Serve struct {
 		Address string
@@ -31,6 +45,8 @@ type Config struct {
 	}
 	Decode struct {
 		Token string
+		// Audience, if not empty, must be in the "aud" claim of Token.
+		Audience string
 	}
 }
 
@@ -39,3 +55,23 @@ var cmdflg = Config{}
 func FixedStoreFromConfig(c Config) chame.Store {
 	return memstore.Fixed(c.Issuer, []byte(c.Secret))
 }
+
+// StoreFromConfig returns a Store that loads keys from c.KeyDir, or holds a
+// key loaded from c.KeyFile, or c.Secret if both are empty.
+func StoreFromConfig(c Config) (chame.Store, error) {
+	if c.KeyDir != "" {
+		return keydir.New(c.KeyDir, c.KeyDirPollInterval)
+	}
+	if c.KeyFile == "" {
+		return FixedStoreFromConfig(c), nil
+	}
+	data, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := chame.ParsePEMKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.KeyFile, err)
+	}
+	return memstore.Fixed(c.Issuer, key), nil
+}
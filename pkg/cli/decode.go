@@ -33,17 +33,26 @@ func newDecodeCmd() *cobra.Command {
 	flags := cmd.PersistentFlags()
 	flags.StringVar(&cmdflg.Issuer, "issuer", "https://chame.yosida95.com", "URL to identify token issuer")
 	flags.StringVar(&cmdflg.Secret, "secret", "dummysecret", "HMAC shared secret to sign/verify tokens")
+	flags.StringVar(&cmdflg.KeyFile, "key-file", "", "path to a PEM-encoded private key to sign/verify tokens in place of --secret")
+	flags.StringVar(&cmdflg.KeyDir, "key-dir", "", "path to a directory of keys to sign/verify tokens in place of --key-file and --secret")
 	flags.StringVar(&cmdflg.Decode.Token, "token", "", "signed token to decode")
+	flags.StringVar(&cmdflg.Decode.Audience, "audience", "", "audience the token must be intended for")
 	return cmd
 }
 
 func runDecode(*cobra.Command, []string) {
-	store := FixedStoreFromConfig(cmdflg)
-	decoded, err := chame.DecodeToken(context.Background(), store, cmdflg.Decode.Token)
+	store, err := StoreFromConfig(cmdflg)
+	if err != nil {
+		glog.Exitf("failed to load a key: %v", err)
+		return
+	}
+	claims, err := chame.DecodeClaimsWithOption(context.Background(), store, cmdflg.Decode.Token, chame.DecodeOption{
+		Audience: cmdflg.Decode.Audience,
+	})
 	if err != nil {
 		glog.Exitf("failed to decode token: %v", err)
 		return
 	}
 
-	fmt.Fprintln(os.Stdout, decoded)
+	fmt.Fprintln(os.Stdout, claims.Subject)
 }
@@ -33,12 +33,18 @@ func newEncodeCmd() *cobra.Command {
 	flags := cmd.PersistentFlags()
 	flags.StringVar(&cmdflg.Issuer, "issuer", "https://chame.yosida95.com", "URL to identify token issuer")
 	flags.StringVar(&cmdflg.Secret, "secret", "dummysecret", "HMAC shared secret to sign/verify tokens")
+	flags.StringVar(&cmdflg.KeyFile, "key-file", "", "path to a PEM-encoded private key to sign/verify tokens in place of --secret")
+	flags.StringVar(&cmdflg.KeyDir, "key-dir", "", "path to a directory of keys to sign/verify tokens in place of --key-file and --secret")
 	flags.StringVar(&cmdflg.Encode.URL, "url", "https://example.com/", "URL to encode")
 	return cmd
 }
 
 func runEncode(*cobra.Command, []string) {
-	store := FixedStoreFromConfig(cmdflg)
+	store, err := StoreFromConfig(cmdflg)
+	if err != nil {
+		glog.Exitf("failed to load a key: %v", err)
+		return
+	}
 	token := &chame.Token{
 		Issuer:  cmdflg.Issuer,
 		Subject: cmdflg.Encode.URL,
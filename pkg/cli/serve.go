@@ -25,6 +25,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"github.com/yosida95/chame/pkg/chame"
+	"github.com/yosida95/chame/pkg/keydir"
 )
 
 func newServeCmd() *cobra.Command {
@@ -37,16 +38,44 @@ func newServeCmd() *cobra.Command {
 	flags.StringVar(&cmdflg.Serve.Address, "listen", "0.0.0.0:8080", "address and port chame will accept requests")
 	flags.StringVar(&cmdflg.Issuer, "issuer", "https://chame.yosida95.com", "URL to identify token issuer")
 	flags.StringVar(&cmdflg.Secret, "secret", "dummysecret", "HMAC shared secret to sign/verify tokens")
+	flags.StringVar(&cmdflg.KeyFile, "key-file", "", "path to a PEM-encoded private key to sign/verify tokens in place of --secret")
+	flags.StringVar(&cmdflg.KeyDir, "key-dir", "", "path to a directory of keys to sign/verify tokens in place of --key-file and --secret")
+	flags.DurationVar(&cmdflg.KeyDirPollInterval, "key-dir-poll", 30*time.Second, "interval to check --key-dir for changes; 0 disables it")
 	return cmd
 }
 
 func runServe(*cobra.Command, []string) {
+	store, err := StoreFromConfig(cmdflg)
+	if err != nil {
+		glog.Exitf("chame: failed to load a key: %v", err)
+		return
+	}
+	if dir, ok := store.(*keydir.Store); ok {
+		defer dir.Close()
+
+		hupch := make(chan os.Signal, 1)
+		signal.Notify(hupch, syscall.SIGHUP)
+		defer signal.Stop(hupch)
+		go func() {
+			for range hupch {
+				if err := dir.Reload(); err != nil {
+					glog.Warningf("chame: failed to reload keys: %v", err)
+					continue
+				}
+				glog.Infof("chame: Reloaded keys from %q", cmdflg.KeyDir)
+			}
+		}()
+	}
+	handler := &chame.Chame{
+		Proxy: &chame.HTTPProxy{},
+		Store: store,
+	}
+	if err := handler.Validate(); err != nil {
+		glog.Exitln(err)
+	}
 	srv := &http.Server{
-		Addr: cmdflg.Serve.Address,
-		Handler: &chame.Chame{
-			Proxy: &chame.HTTPProxy{},
-			Store: FixedStoreFromConfig(cmdflg),
-		},
+		Addr:    cmdflg.Serve.Address,
+		Handler: handler,
 	}
 
 	errch := make(chan error, 1)
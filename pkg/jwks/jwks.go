@@ -0,0 +1,304 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jwks provides chame.Store that loads verifying keys from a JSON Web
+// Key Set (RFC 7517) in a file or at a URL.
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+const (
+	// DefaultRefreshInterval is the interval of refreshing key sets if
+	// Options.RefreshInterval is zero.
+	DefaultRefreshInterval = 15 * time.Minute
+	// DefaultMinRefreshInterval is the minimum interval of refreshing key
+	// sets on unknown key IDs if Options.MinRefreshInterval is zero.
+	DefaultMinRefreshInterval = 1 * time.Minute
+
+	maxKeySetSize = 1 << 20
+)
+
+// Options customizes Store.
+type Options struct {
+	// Issuer restricts the keys to tokens of the issuer (the "iss" claim).
+	// If Issuer is empty, the keys are used for any issuer.
+	Issuer string
+	// RefreshInterval is the interval of refreshing the key set. If
+	// negative, the key set is not refreshed periodically.
+	RefreshInterval time.Duration
+	// MinRefreshInterval limits the rate of refreshing the key set when a
+	// token has an unknown key ID.
+	MinRefreshInterval time.Duration
+	// HTTPClient is used to fetch the key set from a URL. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// Store is chame.Store that provides verifying keys in a JSON Web Key Set.
+// RSA, EC, OKP (Ed25519) and oct keys are supported, and keys for encryption
+// ("use": "enc") are ignored. When the key set fails to be refreshed, the last
+// loaded one remains in use, and key IDs not in it are regarded as unknown.
+//
+// Store does not provide signing keys.
+type Store struct {
+	issuer      string
+	minInterval time.Duration
+	fetch       func(ctx context.Context) ([]byte, error)
+
+	keys atomic.Pointer[keySet]
+
+	// mu serializes refreshing, and lastTry is the time of the last try.
+	mu      sync.Mutex
+	lastTry time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var (
+	_ chame.Store        = (*Store)(nil)
+	_ chame.StoreContext = (*Store)(nil)
+)
+
+// NewFile loads the key set in the file at path.
+func NewFile(path string, opts *Options) (*Store, error) {
+	return newStore(func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}, opts)
+}
+
+// NewURL fetches the key set at url.
+func NewURL(url string, opts *Options) (*Store, error) {
+	client := http.DefaultClient
+	if opts != nil && opts.HTTPClient != nil {
+		client = opts.HTTPClient
+	}
+	return newStore(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/jwk-set+json, application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	}, opts)
+}
+
+func newStore(fetch func(context.Context) ([]byte, error), opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := &Store{
+		issuer:      opts.Issuer,
+		minInterval: opts.MinRefreshInterval,
+		fetch:       fetch,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if s.minInterval == 0 {
+		s.minInterval = DefaultMinRefreshInterval
+	}
+	if err := s.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	interval := opts.RefreshInterval
+	if interval == 0 {
+		interval = DefaultRefreshInterval
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+func (s *Store) run(interval time.Duration) {
+	defer close(s.done)
+	if interval < 0 {
+		<-s.stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Refresh(context.Background()); err != nil {
+				log.Printf("chame: failed to refresh JWKS: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops refreshing the key set periodically.
+func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+	return nil
+}
+
+// Refresh loads the key set. If it fails, the last loaded key set remains in
+// use.
+func (s *Store) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refresh(ctx)
+}
+
+func (s *Store) refresh(ctx context.Context) error {
+	s.lastTry = time.Now()
+	data, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+	s.keys.Store(keys)
+	return nil
+}
+
+// refreshOnMiss refreshes the key set unless it is refreshed recently, and
+// reports whether it is refreshed. loaded is the key set in which the miss
+// occurred.
+func (s *Store) refreshOnMiss(ctx context.Context, loaded *keySet) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys.Load() != loaded {
+		// NOTE(yosida95): refreshed by another goroutine meanwhile.
+		return true, nil
+	}
+	if time.Since(s.lastTry) < s.minInterval {
+		return false, nil
+	}
+	if err := s.refresh(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) GetVerifyingKeyContext(ctx context.Context, iss string, kid string) (interface{}, error) {
+	if s.issuer != "" && iss != s.issuer {
+		return nil, chame.ErrKeyNotFound
+	}
+	keys := s.keys.Load()
+	if key := keys.lookup(kid); key != nil {
+		return key, nil
+	}
+	refreshed, err := s.refreshOnMiss(ctx, keys)
+	if err != nil {
+		// NOTE(yosida95): the last loaded key set, which newStore
+		// guarantees, is authoritative while the source is down, so that
+		// arbitrary key IDs cannot make tokens look unavailable.
+		log.Printf("chame: failed to refresh JWKS: %v", err)
+	}
+	if refreshed {
+		if key := s.keys.Load().lookup(kid); key != nil {
+			return key, nil
+		}
+	}
+	return nil, chame.ErrKeyNotFound
+}
+
+func (s *Store) GetVerifyingKey(iss string, kid string) (interface{}, error) {
+	return s.GetVerifyingKeyContext(context.Background(), iss, kid)
+}
+
+var errSigningNotSupported = errors.New("chame: JWKS does not provide signing keys")
+
+func (s *Store) GetSigningKeyContext(context.Context, string, string) (interface{}, error) {
+	return nil, errSigningNotSupported
+}
+
+func (s *Store) GetSigningKey(string, string) (interface{}, error) {
+	return nil, errSigningNotSupported
+}
+
+// keySet is a parsed key set.
+type keySet struct {
+	byKid map[string]chame.KeySet
+	all   chame.KeySet
+}
+
+// lookup returns keys for kid. If kid is empty, all the keys are returned.
+func (keys *keySet) lookup(kid string) chame.KeySet {
+	if kid == "" {
+		return keys.all
+	}
+	return keys.byKid[kid]
+}
+
+func parseKeySet(data []byte) (*keySet, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("chame: malformed JWKS: %w", err)
+	}
+	keys := &keySet{byKid: make(map[string]chame.KeySet)}
+	for i, raw := range set.Keys {
+		// NOTE(yosida95): unsupported keys are skipped so as not to fail
+		// the others.
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON(raw); err != nil {
+			log.Printf("chame: skipped JWK #%d: %v", i, err)
+			continue
+		}
+		if jwk.Use == "enc" {
+			continue
+		}
+		material := jwk.Key
+		if _, ok := material.([]byte); !ok && !jwk.IsPublic() {
+			material = jwk.Public().Key
+		}
+		key := &chame.Key{
+			Material:  material,
+			Algorithm: jwk.Algorithm,
+			Name:      jwk.KeyID,
+		}
+		keys.all = append(keys.all, key)
+		if jwk.KeyID != "" {
+			keys.byKid[jwk.KeyID] = append(keys.byKid[jwk.KeyID], key)
+		}
+	}
+	if len(keys.all) == 0 {
+		return nil, errors.New("chame: JWKS has no usable keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+const testIssuer = "https://chame.example.net"
+
+// signer is a chame.Store that provides a signing key.
+type signer struct {
+	key interface{}
+}
+
+func (s signer) GetSigningKey(string, string) (interface{}, error) { return s.key, nil }
+func (s signer) GetVerifyingKey(string, string) (interface{}, error) {
+	return nil, chame.ErrKeyNotFound
+}
+
+func sign(t *testing.T, key interface{}, kid string) string {
+	t.Helper()
+	token, err := chame.EncodeToken(context.Background(), signer{key}, &chame.Token{
+		Issuer:  testIssuer,
+		Subject: "https://example.com/cat.png",
+	}, kid)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return token
+}
+
+func marshalKeySet(t *testing.T, keys map[string]interface{}) []byte {
+	t.Helper()
+	var set jose.JSONWebKeySet
+	for kid, key := range keys {
+		jwk := jose.JSONWebKey{Key: key, KeyID: kid, Use: "sig"}
+		if _, ok := key.([]byte); !ok {
+			jwk = jwk.Public()
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+type jwksServer struct {
+	mu       sync.Mutex
+	data     []byte
+	fail     bool
+	requests atomic.Int32
+}
+
+func (srv *jwksServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	srv.requests.Add(1)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Write(srv.data)
+}
+
+func (srv *jwksServer) set(data []byte, fail bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.data, srv.fail = data, fail
+}
+
+func TestStore(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+	rotated, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	srv := &jwksServer{}
+	srv.set(marshalKeySet(t, map[string]interface{}{
+		"rsa": rsaKey,
+		"ec":  ecKey,
+		"ed":  edKey,
+		"oct": hmacKey,
+	}), false)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	store, err := NewURL(ts.URL, &Options{
+		Issuer:             testIssuer,
+		RefreshInterval:    -1,
+		MinRefreshInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	for _, c := range []struct {
+		key crypto.PrivateKey
+		kid string
+	}{
+		{key: rsaKey, kid: "rsa"},
+		{key: ecKey, kid: "ec"},
+		{key: edKey, kid: "ed"},
+		{key: hmacKey, kid: "oct"},
+		{key: ecKey},
+	} {
+		claims, err := chame.DecodeClaims(context.Background(), store, sign(t, c.key, c.kid))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.kid, err)
+			continue
+		}
+		if c.kid != "" && claims.KeyName != c.kid {
+			t.Errorf("%s: verified with %q", c.kid, claims.KeyName)
+		}
+	}
+
+	if _, err := store.GetVerifyingKey("https://other.example.net", "rsa"); !errors.Is(err, chame.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound for another issuer, have %v", err)
+	}
+
+	// an unknown kid triggers refreshing, which is rate limited
+	time.Sleep(50 * time.Millisecond)
+	srv.set(marshalKeySet(t, map[string]interface{}{"rotated": rotated}), false)
+	requests := srv.requests.Load()
+	if _, err := chame.DecodeClaims(context.Background(), store, sign(t, rotated, "rotated")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := store.GetVerifyingKey(testIssuer, "unknown"); !errors.Is(err, chame.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, have %v", err)
+	}
+	if n := srv.requests.Load() - requests; n != 1 {
+		t.Errorf("expected 1 request, have %d", n)
+	}
+
+	// the last known good key set remains on failures
+	time.Sleep(50 * time.Millisecond)
+	srv.set(nil, true)
+	requests = srv.requests.Load()
+	if _, err := store.GetVerifyingKey(testIssuer, "unknown"); !errors.Is(err, chame.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound while refreshing fails, have %v", err)
+	}
+	if n := srv.requests.Load() - requests; n != 1 {
+		t.Errorf("expected 1 request, have %d", n)
+	}
+	if _, err := chame.DecodeClaims(context.Background(), store, sign(t, rotated, "rotated")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := store.GetSigningKey(testIssuer, "rotated"); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	data := marshalKeySet(t, map[string]interface{}{"ec": ecKey})
+	// unsupported keys are skipped
+	data = append(data[:len(data)-2], []byte(`,{"kty":"unknown","kid":"x"}]}`)...)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFile(path, &Options{RefreshInterval: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+	if _, err := chame.DecodeClaims(context.Background(), store, sign(t, ecKey, "ec")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"keys":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path, nil); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}
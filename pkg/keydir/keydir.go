@@ -0,0 +1,285 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keydir provides chame.Store that loads keys from files in a
+// directory.
+package keydir
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+// DefaultKeyID is the key ID of keys used for tokens without the "kid"
+// header.
+const DefaultKeyID = "default"
+
+// Store is chame.Store that loads keys from files in a directory.
+//
+// The directory contains a subdirectory for each issuer, which is named by
+// the issuer with "/" escaped as "%2F", such as "https:%2F%2Fchame.example.com".
+// Each subdirectory contains a file for each key ID, which is named by the
+// key ID followed by the extension:
+//
+//   - ".pem" for a PEM-encoded private or public key of RSA, ECDSA or
+//     Ed25519. Private keys are used to sign and verify, and public keys are
+//     used only to verify.
+//   - ".key" for a raw HMAC secret, whose content is used as is.
+//
+// The key of DefaultKeyID is used for tokens without the "kid" header. Files
+// of other extensions and those starting with "." are ignored.
+type Store struct {
+	dir  string
+	keys atomic.Pointer[keyMap]
+
+	// mu serializes reloading, and stamp is the state of the files loaded
+	// last time to detect changes.
+	mu    sync.Mutex
+	stamp string
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var _ chame.Store = (*Store)(nil)
+
+type keyRef struct {
+	iss string
+	kid string
+}
+
+type keyEntry struct {
+	signing   interface{}
+	verifying interface{}
+}
+
+type keyMap map[keyRef]*keyEntry
+
+// New loads keys from dir, and reloads them every pollInterval if any file
+// is changed. If pollInterval is not positive, keys are reloaded only by
+// Reload. New fails if any file is malformed, while reloading keeps the keys
+// loaded last time in that case.
+func New(dir string, pollInterval time.Duration) (*Store, error) {
+	s := &Store{
+		dir:  dir,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	go s.run(pollInterval)
+	return s, nil
+}
+
+func (s *Store) run(interval time.Duration) {
+	defer close(s.done)
+	if interval <= 0 {
+		<-s.stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.reloadIfChanged(); err != nil {
+				log.Printf("chame: failed to reload keys: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops polling the directory.
+func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+	return nil
+}
+
+// Reload loads keys from the directory. If any file is malformed, Reload
+// returns errors for all of them and the keys loaded last time remain in use.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp, err := s.fingerprint()
+	if err != nil {
+		return err
+	}
+	return s.load(stamp)
+}
+
+func (s *Store) reloadIfChanged() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp, err := s.fingerprint()
+	if err != nil {
+		return err
+	}
+	if stamp == s.stamp {
+		return nil
+	}
+	return s.load(stamp)
+}
+
+// fingerprint summarizes names, sizes and modification times of the files.
+func (s *Store) fingerprint() (string, error) {
+	var buf strings.Builder
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (s *Store) load(stamp string) error {
+	issuers, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	keys := make(keyMap)
+	var errs []error
+	for _, issDir := range issuers {
+		if strings.HasPrefix(issDir.Name(), ".") {
+			continue
+		}
+		if !issDir.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: not a directory of an issuer", filepath.Join(s.dir, issDir.Name())))
+			continue
+		}
+		iss, err := unescapeIssuer(issDir.Name())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: malformed issuer: %w", filepath.Join(s.dir, issDir.Name()), err))
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.dir, issDir.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, file := range files {
+			name := file.Name()
+			ext := filepath.Ext(name)
+			if strings.HasPrefix(name, ".") || file.IsDir() || (ext != ".pem" && ext != ".key") {
+				continue
+			}
+			path := filepath.Join(s.dir, issDir.Name(), name)
+			ref := keyRef{iss: iss, kid: strings.TrimSuffix(name, ext)}
+			if _, ok := keys[ref]; ok {
+				errs = append(errs, fmt.Errorf("%s: duplicate key ID %q", path, ref.kid))
+				continue
+			}
+			entry, err := loadKey(path, ext)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			keys[ref] = entry
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	s.keys.Store(&keys)
+	s.stamp = stamp
+	return nil
+}
+
+func loadKey(path string, ext string) (*keyEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if ext == ".key" {
+		if len(data) == 0 {
+			return nil, errors.New("empty secret")
+		}
+		return &keyEntry{signing: data, verifying: data}, nil
+	}
+
+	key, err := chame.ParsePEMKey(data)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return &keyEntry{
+			signing:   key,
+			verifying: key.(crypto.Signer).Public(),
+		}, nil
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return &keyEntry{verifying: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+func (s *Store) lookup(iss string, kid string) *keyEntry {
+	if kid == "" {
+		kid = DefaultKeyID
+	}
+	return (*s.keys.Load())[keyRef{iss: iss, kid: kid}]
+}
+
+func (s *Store) GetVerifyingKey(iss string, kid string) (interface{}, error) {
+	if entry := s.lookup(iss, kid); entry != nil {
+		return entry.verifying, nil
+	}
+	return nil, chame.ErrKeyNotFound
+}
+
+func (s *Store) GetSigningKey(iss string, kid string) (interface{}, error) {
+	if entry := s.lookup(iss, kid); entry != nil && entry.signing != nil {
+		return entry.signing, nil
+	}
+	return nil, chame.ErrKeyNotFound
+}
+
+// IssuerDir returns the name of the subdirectory for iss.
+func IssuerDir(iss string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(iss, "%", "%25"), "/", "%2F")
+}
+
+func unescapeIssuer(name string) (string, error) {
+	return url.PathUnescape(name)
+}
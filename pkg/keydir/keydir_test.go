@@ -0,0 +1,214 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keydir
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+const testIssuer = "https://chame.example.net"
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func privatePEM(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func encode(store chame.Store, iss string, kid string) (string, error) {
+	return chame.EncodeToken(context.Background(), store, &chame.Token{
+		Issuer:  iss,
+		Subject: "https://example.com/cat.png",
+	}, kid)
+}
+
+func TestStore(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	issDir := filepath.Join(dir, IssuerDir(testIssuer))
+	writeFile(t, filepath.Join(issDir, "default.key"), []byte("s3cr3t"))
+	writeFile(t, filepath.Join(issDir, "ed.pem"), privatePEM(t, edPriv))
+	writeFile(t, filepath.Join(issDir, "ec.pem"), publicPEM(t, ecPriv.Public()))
+	writeFile(t, filepath.Join(issDir, "README"), []byte("ignored"))
+
+	store, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer store.Close()
+
+	for _, kid := range []string{"", "default", "ed"} {
+		token, err := encode(store, testIssuer, kid)
+		if err != nil {
+			t.Errorf("kid %q: failed to sign: %v", kid, err)
+			continue
+		}
+		if _, err := chame.DecodeToken(context.Background(), store, token); err != nil {
+			t.Errorf("kid %q: failed to verify: %v", kid, err)
+		}
+	}
+
+	// Public keys can only verify.
+	if _, err := store.GetSigningKey(testIssuer, "ec"); !errors.Is(err, chame.ErrKeyNotFound) {
+		t.Errorf("GetSigningKey(ec) = %v, want ErrKeyNotFound", err)
+	}
+	key, err := store.GetVerifyingKey(testIssuer, "ec")
+	if err != nil || !ecPriv.PublicKey.Equal(key) {
+		t.Errorf("GetVerifyingKey(ec) = %v, %v", key, err)
+	}
+
+	for _, ref := range []keyRef{
+		{iss: testIssuer, kid: "unknown"},
+		{iss: "https://other.example.net", kid: "ed"},
+	} {
+		if _, err := store.GetVerifyingKey(ref.iss, ref.kid); !errors.Is(err, chame.ErrKeyNotFound) {
+			t.Errorf("GetVerifyingKey(%q, %q) = %v, want ErrKeyNotFound", ref.iss, ref.kid, err)
+		}
+	}
+}
+
+func TestNew_malformed(t *testing.T) {
+	dir := t.TempDir()
+	issDir := filepath.Join(dir, IssuerDir(testIssuer))
+	writeFile(t, filepath.Join(issDir, "bad.pem"), []byte("not a key"))
+	writeFile(t, filepath.Join(issDir, "empty.key"), nil)
+	writeFile(t, filepath.Join(issDir, "dup.key"), []byte("s3cr3t"))
+	writeFile(t, filepath.Join(issDir, "dup.pem"), []byte("not a key either"))
+	writeFile(t, filepath.Join(dir, "stray.pem"), []byte("not in an issuer"))
+
+	_, err := New(dir, 0)
+	if err == nil {
+		t.Fatal("New succeeded with malformed files")
+	}
+	for _, want := range []string{
+		filepath.Join(issDir, "bad.pem"),
+		filepath.Join(issDir, "empty.key"),
+		filepath.Join(issDir, "dup.pem"),
+		filepath.Join(dir, "stray.pem"),
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	issDir := filepath.Join(dir, IssuerDir(testIssuer))
+	writeFile(t, filepath.Join(issDir, "old.key"), []byte("old"))
+
+	store, err := New(dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer store.Close()
+
+	writeFile(t, filepath.Join(issDir, "new.key"), []byte("new"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := store.GetVerifyingKey(testIssuer, "new"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("new key was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A malformed file keeps the keys loaded last time.
+	writeFile(t, filepath.Join(issDir, "bad.pem"), []byte("not a key"))
+	if err := store.Reload(); err == nil {
+		t.Error("Reload succeeded with a malformed file")
+	}
+	for _, kid := range []string{"old", "new"} {
+		if _, err := store.GetVerifyingKey(testIssuer, kid); err != nil {
+			t.Errorf("GetVerifyingKey(%q) after failed reload: %v", kid, err)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(issDir, "bad.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(issDir, "old.key")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if _, err := store.GetVerifyingKey(testIssuer, "old"); !errors.Is(err, chame.ErrKeyNotFound) {
+		t.Errorf("removed key is still available: %v", err)
+	}
+}
+
+func TestIssuerDir(t *testing.T) {
+	for _, iss := range []string{
+		"https://chame.example.net",
+		"https://chame.example.net/a%2Fb",
+		"urn:chame",
+	} {
+		name := IssuerDir(iss)
+		if strings.Contains(name, "/") {
+			t.Errorf("IssuerDir(%q) = %q contains a slash", iss, name)
+		}
+		got, err := unescapeIssuer(name)
+		if err != nil || got != iss {
+			t.Errorf("unescapeIssuer(%q) = %q, %v, want %q", name, got, err, iss)
+		}
+	}
+}
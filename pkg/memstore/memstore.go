@@ -15,7 +15,10 @@
 package memstore
 
 import (
-	"fmt"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"sync"
 
 	"github.com/yosida95/chame/pkg/chame"
@@ -29,8 +32,12 @@ type entryKey struct {
 type MemStore struct {
 	mu     sync.RWMutex
 	values map[entryKey]interface{}
+	// encKeys holds keys to decrypt tokens.
+	encKeys map[entryKey]interface{}
 }
 
+var _ chame.EncryptingStore = (*MemStore)(nil)
+
 func New() chame.Store {
 	return &MemStore{}
 }
@@ -57,18 +64,77 @@ func (ms *MemStore) Set(iss string, kid string, value interface{}) {
 	ms.values[entryKey{iss, kid}] = value
 }
 
+// GetVerifyingKey returns the key set by Set. If it is a private key, its
+// public counterpart is returned.
 func (ms *MemStore) GetVerifyingKey(iss string, kid string) (interface{}, error) {
 	key := ms.Get(iss, kid)
 	if key == nil {
-		return nil, fmt.Errorf("chame: key not found")
+		return nil, chame.ErrKeyNotFound
 	}
-	return key, nil
+	return publicKey(key), nil
 }
 
 func (ms *MemStore) GetSigningKey(iss string, kid string) (interface{}, error) {
 	key := ms.Get(iss, kid)
 	if key == nil {
-		return nil, fmt.Errorf("chame: key not found")
+		return nil, chame.ErrKeyNotFound
 	}
 	return key, nil
 }
+
+// SetEncryptionKey sets a key to encrypt and decrypt tokens. value must be
+// []byte, *rsa.PrivateKey or *ecdsa.PrivateKey, and its public counterpart is
+// used to encrypt tokens.
+func (ms *MemStore) SetEncryptionKey(iss string, kid string, value interface{}) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.encKeys == nil {
+		ms.encKeys = make(map[entryKey]interface{})
+	}
+	ms.encKeys[entryKey{iss, kid}] = value
+}
+
+func (ms *MemStore) GetEncryptingKey(iss string, kid string) (interface{}, error) {
+	key, err := ms.GetDecryptingKey(iss, kid)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey(key), nil
+}
+
+func (ms *MemStore) GetDecryptingKey(iss string, kid string) (interface{}, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	key := ms.encKeys[entryKey{iss, kid}]
+	if key == nil {
+		return nil, chame.ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// publicKey returns the public key of key if key is a private key of an
+// asymmetric algorithm, or key itself otherwise.
+func publicKey(key interface{}) interface{} {
+	switch key := key.(type) {
+	case chame.KeySet:
+		set := make(chame.KeySet, len(key))
+		for i := range key {
+			set[i] = publicKey(key[i]).(*chame.Key)
+		}
+		return set
+	case *chame.Key:
+		pub := *key
+		pub.Material = publicKey(key.Material)
+		return &pub
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey
+	case ed25519.PrivateKey:
+		return key.Public()
+	case crypto.Signer:
+		return key.Public()
+	}
+	return key
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+// File is a list of revoked URLs loaded from a file, which is reloaded
+// periodically when modified. The file should be updated by renaming a new
+// file over it, while a file found to be modified during loading is loaded
+// again at the next check and the last loaded list remains in use until then.
+//
+// Each line of the file is one of the following forms. Empty lines and lines
+// starting with "#" are ignored.
+//
+//	jti <id>
+//	sub <original URL>
+//	kid <issuer> <key ID>
+type File struct {
+	path string
+	list atomic.Pointer[List]
+	// stat is of the file loaded last time.
+	stat os.FileInfo
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var _ chame.RevocationChecker = (*File)(nil)
+
+// NewFile loads the file at path and starts reloading it every interval. If
+// the file cannot be loaded later, the last loaded list remains in use. If
+// interval is not positive, the file is loaded only once.
+func NewFile(path string, interval time.Duration) (*File, error) {
+	f := &File{
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	go f.run(interval)
+	return f, nil
+}
+
+func (f *File) run(interval time.Duration) {
+	defer close(f.done)
+	if interval <= 0 {
+		<-f.stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			if err := f.reload(); err != nil {
+				log.Printf("chame: failed to reload revocation list: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops reloading the file.
+func (f *File) Close() error {
+	f.once.Do(func() {
+		close(f.stop)
+		<-f.done
+	})
+	return nil
+}
+
+func (f *File) reload() error {
+	fp, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	stat, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	if f.stat != nil && sameContent(stat, f.stat) {
+		return nil
+	}
+	data, err := io.ReadAll(fp)
+	if err != nil {
+		return err
+	}
+	// NOTE(yosida95): a file being written in place may be read partially,
+	// which would un-revoke entries not read yet.
+	after, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != stat.Size() || !os.SameFile(stat, after) || !sameContent(stat, after) {
+		return fmt.Errorf("%s: modified while loading", f.path)
+	}
+	list, err := parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	f.list.Store(list)
+	f.stat = stat
+	return nil
+}
+
+// sameContent reports whether a and b are likely of the same content.
+func sameContent(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+func (f *File) IsRevoked(ctx context.Context, q *chame.RevocationQuery) (bool, error) {
+	return f.list.Load().IsRevoked(ctx, q)
+}
+
+func parse(r io.Reader) (*List, error) {
+	list := New()
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "jti" && len(fields) == 2:
+			list.RevokeID(fields[1])
+		case fields[0] == "sub" && len(fields) == 2:
+			list.RevokeSubject(fields[1])
+		case fields[0] == "kid" && len(fields) == 3:
+			list.RevokeKey(fields[1], fields[2])
+		default:
+			return nil, fmt.Errorf("line %d: malformed entry", lineno)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked")
+	write := func(content string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write("# revoked URLs\njti abc\n\nkid https://chame.example.com old\n", now)
+
+	f, err := NewFile(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	check := func(q chame.RevocationQuery, expect bool) {
+		t.Helper()
+		if have, err := f.IsRevoked(context.Background(), &q); err != nil || have != expect {
+			t.Errorf("%+v: expect %t, got %t (%v)", q, expect, have, err)
+		}
+	}
+	check(chame.RevocationQuery{ID: "abc"}, true)
+	check(chame.RevocationQuery{Issuer: "https://chame.example.com", KeyID: "old"}, true)
+	check(chame.RevocationQuery{Issuer: "https://chame.example.com", KeyID: "new"}, false)
+	check(chame.RevocationQuery{Subject: "https://example.com/cat.png"}, false)
+
+	// malformed files are ignored on reload
+	write("jti\n", now.Add(time.Second))
+	time.Sleep(50 * time.Millisecond)
+	check(chame.RevocationQuery{ID: "abc"}, true)
+
+	write("sub https://example.com/cat.png\n", now.Add(2*time.Second))
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		// NOTE: the file may be observed while being truncated and written,
+		// so wait for the new entry rather than the removal of the old one.
+		if revoked, _ := f.IsRevoked(context.Background(), &chame.RevocationQuery{Subject: "https://example.com/cat.png"}); revoked {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	check(chame.RevocationQuery{ID: "abc"}, false)
+	check(chame.RevocationQuery{Subject: "https://example.com/cat.png"}, true)
+}
+
+func TestNewFile_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked")
+	if err := os.WriteFile(path, []byte("jti a b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path, time.Minute); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}
+
+func TestNewFile_NoReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked")
+	if err := os.WriteFile(path, []byte("jti abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFile(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if revoked, err := f.IsRevoked(context.Background(), &chame.RevocationQuery{ID: "abc"}); err != nil || !revoked {
+		t.Errorf("expect revoked, got %t (%v)", revoked, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
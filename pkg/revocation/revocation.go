@@ -0,0 +1,81 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revocation provides implementations of chame.RevocationChecker.
+package revocation
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+type keyRef struct {
+	iss string
+	kid string
+}
+
+// List is an in-memory list of revoked URLs.
+type List struct {
+	mu       sync.RWMutex
+	ids      map[string]struct{}
+	subjects map[string]struct{}
+	keys     map[keyRef]struct{}
+}
+
+var _ chame.RevocationChecker = (*List)(nil)
+
+func New() *List {
+	return &List{
+		ids:      make(map[string]struct{}),
+		subjects: make(map[string]struct{}),
+		keys:     make(map[keyRef]struct{}),
+	}
+}
+
+// RevokeID revokes URLs whose "jti" claim is id.
+func (l *List) RevokeID(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ids[id] = struct{}{}
+}
+
+// RevokeSubject revokes URLs signed for the original URL sub.
+func (l *List) RevokeSubject(sub string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subjects[sub] = struct{}{}
+}
+
+// RevokeKey revokes URLs signed with the key identified by iss and kid.
+func (l *List) RevokeKey(iss string, kid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys[keyRef{iss, kid}] = struct{}{}
+}
+
+func (l *List) IsRevoked(_ context.Context, q *chame.RevocationQuery) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.ids[q.ID]; ok && q.ID != "" {
+		return true, nil
+	}
+	if _, ok := l.subjects[q.Subject]; ok {
+		return true, nil
+	}
+	_, ok := l.keys[keyRef{q.Issuer, q.KeyID}]
+	return ok, nil
+}
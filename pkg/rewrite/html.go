@@ -0,0 +1,222 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTML copies the HTML document or fragment from r to w, rewriting URLs of
+// images to signed URLs. The rewritten attributes are src and srcset of img,
+// srcset of source in picture, poster of video, src of input with
+// type="image", and url() in style of any element. Tags whose attributes are
+// not rewritten are written as they are.
+func (rw *Rewriter) HTML(ctx context.Context, w io.Writer, r io.Reader) error {
+	s := rw.newSigner(ctx)
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			return nil
+		}
+		raw := append([]byte(nil), z.Raw()...)
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			tok := z.Token()
+			rewritten, err := s.rewriteTag(&tok)
+			if err != nil {
+				return err
+			}
+			if rewritten {
+				raw = []byte(tok.String())
+			}
+		}
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// HTMLString is like HTML but rewrites a string.
+func (rw *Rewriter) HTMLString(ctx context.Context, src string) (string, error) {
+	var buf bytes.Buffer
+	if err := rw.HTML(ctx, &buf, strings.NewReader(src)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// rewriteTag rewrites the attributes of tok in place, and reports whether any
+// of them is modified.
+func (s *signer) rewriteTag(tok *html.Token) (bool, error) {
+	var isImageInput bool
+	if tok.Data == "input" {
+		for _, attr := range tok.Attr {
+			if attr.Namespace == "" && attr.Key == "type" && strings.EqualFold(strings.TrimSpace(attr.Val), "image") {
+				isImageInput = true
+			}
+		}
+	}
+
+	modified := false
+	for i := range tok.Attr {
+		attr := &tok.Attr[i]
+		if attr.Namespace != "" {
+			continue
+		}
+		var (
+			val string
+			err error
+		)
+		switch {
+		case attr.Key == "style":
+			val, err = rewriteCSSURLs(attr.Val, s.sign)
+		case tok.Data == "img" && attr.Key == "src",
+			tok.Data == "video" && attr.Key == "poster",
+			isImageInput && attr.Key == "src":
+			trimmed := strings.TrimSpace(attr.Val)
+			if val, err = s.sign(trimmed); val == trimmed {
+				val = attr.Val
+			}
+		case (tok.Data == "img" || tok.Data == "source") && attr.Key == "srcset":
+			val, err = rewriteSrcset(attr.Val, s.sign)
+		default:
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if val != attr.Val {
+			attr.Val = val
+			modified = true
+		}
+	}
+	return modified, nil
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// rewriteSrcset rewrites URLs of image candidates in srcset with sign,
+// leaving separators and descriptors as they are.
+func rewriteSrcset(srcset string, sign func(string) (string, error)) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(srcset); {
+		// leading separators
+		start := i
+		for i < len(srcset) && (isHTMLSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		buf.WriteString(srcset[start:i])
+		if i == len(srcset) {
+			break
+		}
+
+		// URL, which is not followed by descriptors if it ends with commas
+		start = i
+		for i < len(srcset) && !isHTMLSpace(srcset[i]) {
+			i++
+		}
+		end := i
+		for end > start && srcset[end-1] == ',' {
+			end--
+		}
+		signed, err := sign(srcset[start:end])
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(signed)
+		buf.WriteString(srcset[end:i])
+		if end < i {
+			continue
+		}
+
+		// descriptors up to the next comma outside parentheses
+		start = i
+		for depth := 0; i < len(srcset); i++ {
+			if c := srcset[i]; c == '(' {
+				depth++
+			} else if c == ')' && depth > 0 {
+				depth--
+			} else if c == ',' && depth == 0 {
+				break
+			}
+		}
+		buf.WriteString(srcset[start:i])
+	}
+	return buf.String(), nil
+}
+
+// rewriteCSSURLs rewrites URLs in url() functions of CSS declarations with
+// sign. URLs containing escapes are left as they are.
+func rewriteCSSURLs(css string, sign func(string) (string, error)) (string, error) {
+	var buf strings.Builder
+	for {
+		idx := indexFold(css, "url(")
+		if idx < 0 {
+			buf.WriteString(css)
+			return buf.String(), nil
+		}
+		i := idx + len("url(")
+		buf.WriteString(css[:i])
+		css = css[i:]
+
+		i = 0
+		for i < len(css) && isHTMLSpace(css[i]) {
+			i++
+		}
+		start, end := i, -1
+		if i < len(css) && (css[i] == '"' || css[i] == '\'') {
+			quote := css[i]
+			start = i + 1
+			if n := strings.IndexByte(css[start:], quote); n >= 0 {
+				end = start + n
+			}
+		} else if n := strings.IndexByte(css[start:], ')'); n >= 0 {
+			end = start + n
+			for end > start && isHTMLSpace(css[end-1]) {
+				end--
+			}
+		}
+		if end < 0 || strings.ContainsAny(css[start:end], "\\\n") {
+			continue
+		}
+		signed, err := sign(css[start:end])
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(css[:start])
+		buf.WriteString(signed)
+		css = css[end:]
+	}
+}
+
+// indexFold is like strings.Index but ASCII case-insensitive.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
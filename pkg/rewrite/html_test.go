@@ -0,0 +1,132 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rewrite
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yosida95/chame/pkg/chame"
+	"github.com/yosida95/chame/pkg/memstore"
+)
+
+const (
+	testBaseURL = "https://chame.example.net"
+	testIssuer  = "https://chame.example.net"
+)
+
+func newTestRewriter(t *testing.T) *Rewriter {
+	t.Helper()
+	client, err := chame.NewClient(testBaseURL, testIssuer, memstore.Fixed(testIssuer, []byte("secretstring")))
+	if err != nil {
+		t.Fatalf("failed to get new Client: %v", err)
+	}
+	return &Rewriter{Client: client}
+}
+
+// expand replaces each "{}" in tmpl with the signed URL of urls in order.
+func expand(t *testing.T, rw *Rewriter, tmpl string, urls ...string) string {
+	t.Helper()
+	for _, u := range urls {
+		signed, err := rw.Client.Sign(context.Background(), u, rw.SignOption)
+		if err != nil {
+			t.Fatalf("failed to sign %q: %v", u, err)
+		}
+		tmpl = strings.Replace(tmpl, "{}", signed, 1)
+	}
+	return tmpl
+}
+
+func TestHTML(t *testing.T) {
+	rw := newTestRewriter(t)
+	rw.TrustedHosts = []string{"trusted.example.com", ".cdn.example.com"}
+	const (
+		cat = "http://example.com/cat.png"
+		dog = "https://example.com/dog.png"
+	)
+	for _, c := range []struct {
+		in   string
+		out  string
+		urls []string
+	}{
+		{
+			in:   `<p>Hello, <b>world</b>!</p><img src="http://example.com/cat.png" alt="cat">`,
+			out:  `<p>Hello, <b>world</b>!</p><img src="{}" alt="cat">`,
+			urls: []string{cat},
+		},
+		{
+			in:   `<IMG SRC=" http://example.com/cat.png " srcset="http://example.com/cat.png 1x, https://example.com/dog.png 2x">`,
+			out:  `<img src="{}" srcset="{} 1x, {} 2x">`,
+			urls: []string{cat, cat, dog},
+		},
+		{
+			in:   `<picture><source srcset="http://example.com/cat.png, https://example.com/dog.png 100w"><img src="/local.png"></picture>`,
+			out:  `<picture><source srcset="{}, {} 100w"><img src="/local.png"></picture>`,
+			urls: []string{cat, dog},
+		},
+		{
+			in:   `<video poster="http://example.com/cat.png" src="http://example.com/movie.mp4"></video>`,
+			out:  `<video poster="{}" src="http://example.com/movie.mp4"></video>`,
+			urls: []string{cat},
+		},
+		{
+			in:   `<input type="IMAGE" src="http://example.com/cat.png"><input type="text" src="http://example.com/cat.png">`,
+			out:  `<input type="IMAGE" src="{}"><input type="text" src="http://example.com/cat.png">`,
+			urls: []string{cat},
+		},
+		{
+			in:   `<div style="background: URL( 'http://example.com/cat.png' ), url(https://example.com/dog.png)">x</div>`,
+			out:  `<div style="background: URL( &#39;{}&#39; ), url({})">x</div>`,
+			urls: []string{cat, dog},
+		},
+		{
+			in:  `<img src="https://trusted.example.com/a.png"><img src="https://img.cdn.example.com/b.png"><img src="data:image/png;base64,AAAA"><img src="https://chame.example.net/i/abc">`,
+			out: `<img src="https://trusted.example.com/a.png"><img src="https://img.cdn.example.com/b.png"><img src="data:image/png;base64,AAAA"><img src="https://chame.example.net/i/abc">`,
+		},
+		{
+			in:   `<img src="//example.com/cat.png" srcset="\\example.com/dog.png 2x"><img src="//trusted.example.com/a.png"><img src="//chame.example.net/i/abc">`,
+			out:  `<img src="{}" srcset="{} 2x"><img src="//trusted.example.com/a.png"><img src="//chame.example.net/i/abc">`,
+			urls: []string{"https://example.com/cat.png", dog},
+		},
+		{
+			in:  `<script>document.write('<img src="http://example.com/cat.png">')</script><!-- <img src="http://example.com/cat.png"> -->`,
+			out: `<script>document.write('<img src="http://example.com/cat.png">')</script><!-- <img src="http://example.com/cat.png"> -->`,
+		},
+	} {
+		have, err := rw.HTMLString(context.Background(), c.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.in, err)
+			continue
+		}
+		if expected := expand(t, rw, c.out, c.urls...); have != expected {
+			t.Errorf("%s: expected %s, have %s", c.in, expected, have)
+		}
+	}
+}
+
+func TestHTML_InsecureOnly(t *testing.T) {
+	rw := newTestRewriter(t)
+	rw.InsecureOnly = true
+	in := `<img src="https://example.com/dog.png"><img src="http://example.com/cat.png"><img src="//example.com/cat.png">`
+	have, err := rw.HTMLString(context.Background(), in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := expand(t, rw, `<img src="https://example.com/dog.png"><img src="{}"><img src="{}">`, "http://example.com/cat.png", "http://example.com/cat.png")
+	if have != expected {
+		t.Errorf("expected %s, have %s", expected, have)
+	}
+}
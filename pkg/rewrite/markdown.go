@@ -0,0 +1,386 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Markdown rewrites URLs of images in the Markdown source to signed URLs. The
+// rewritten URLs are those of inline images, of link reference definitions
+// used by images, and of autolinks to image files such as
+// <https://example.com/cat.png>. Code spans and code blocks are left as they
+// are, and so are the rest of the source byte for byte.
+func (rw *Rewriter) Markdown(ctx context.Context, src []byte) ([]byte, error) {
+	s := rw.newSigner(ctx)
+	p := mdParser{src: src, imageLabels: make(map[string]bool)}
+	p.parse()
+
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range p.edits() {
+		signed, err := s.sign(string(src[e.start:e.end]))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(src[last:e.start])
+		buf.WriteString(signed)
+		last = e.end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// mdSpan is a range of URL in Markdown source.
+type mdSpan struct {
+	start, end int
+}
+
+// mdRefDef is a link reference definition.
+type mdRefDef struct {
+	label string
+	dest  mdSpan
+}
+
+type mdParser struct {
+	src []byte
+
+	// images are destinations of inline images and autolinks.
+	images []mdSpan
+	// imageLabels are normalized labels of reference images.
+	imageLabels map[string]bool
+	defs        []mdRefDef
+}
+
+// edits returns URL spans to be rewritten in order.
+func (p *mdParser) edits() []mdSpan {
+	edits := append([]mdSpan(nil), p.images...)
+	for _, def := range p.defs {
+		if p.imageLabels[def.label] {
+			edits = append(edits, def.dest)
+		}
+	}
+	// NOTE(yosida95): definitions are placed at line starts, and never
+	// overlap with the others.
+	for i := 1; i < len(edits); i++ {
+		for j := i; j > 0 && edits[j].start < edits[j-1].start; j-- {
+			edits[j], edits[j-1] = edits[j-1], edits[j]
+		}
+	}
+	return edits
+}
+
+var (
+	mdFenceRe  = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	mdRefDefRe = regexp.MustCompile(`^ {0,3}\[((?:[^\[\]\\]|\\.){1,999})\]:[ \t]*(<[^<>\n]*>|[^\s<]\S*)(?:[ \t]+(?:"[^"\n]*"|'[^'\n]*'|\([^()\n]*\)))?[ \t]*$`)
+)
+
+// parse splits the source into lines to find code blocks and link reference
+// definitions, and scans the rest as inline content.
+func (p *mdParser) parse() {
+	var (
+		fence     string
+		inlineEnd = -1
+		inlineBeg = 0
+		prevBlank = true
+		inCode    bool
+	)
+	flush := func(end int) {
+		if inlineEnd >= 0 && inlineBeg < end {
+			p.scanInline(inlineBeg, end)
+		}
+		inlineEnd = -1
+	}
+	for start := 0; start < len(p.src); {
+		end := bytes.IndexByte(p.src[start:], '\n')
+		if end < 0 {
+			end = len(p.src)
+		} else {
+			end += start + 1
+		}
+		line := p.src[start:end]
+		trimmed := bytes.TrimRight(line, "\r\n")
+		blank := len(bytes.TrimSpace(trimmed)) == 0
+
+		switch {
+		case fence != "":
+			// in a fenced code block
+			if m := mdFenceRe.FindSubmatch(trimmed); m != nil &&
+				m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				len(bytes.TrimSpace(trimmed[len(m[0]):])) == 0 {
+				fence = ""
+			}
+		case isIndentedCode(trimmed) && (prevBlank || inCode) && !blank:
+			// NOTE(yosida95): this also regards indented paragraphs in list
+			// items as code, which are left as they are to be safe.
+			flush(start)
+			inCode = true
+		default:
+			if m := mdFenceRe.FindSubmatch(trimmed); m != nil &&
+				(m[1][0] == '~' || !bytes.ContainsRune(trimmed[len(m[0]):], '`')) {
+				flush(start)
+				fence = string(m[1])
+				inCode = false
+				break
+			}
+			if !blank {
+				inCode = false
+			}
+			if m := mdRefDefRe.FindSubmatchIndex(trimmed); m != nil {
+				flush(start)
+				dest := mdSpan{start + m[4], start + m[5]}
+				if p.src[dest.start] == '<' {
+					dest.start, dest.end = dest.start+1, dest.end-1
+				}
+				if bytes.ContainsAny(p.src[dest.start:dest.end], "\\&") {
+					break
+				}
+				p.defs = append(p.defs, mdRefDef{
+					label: normalizeLabel(string(trimmed[m[2]:m[3]])),
+					dest:  dest,
+				})
+				break
+			}
+			if inlineEnd < 0 {
+				inlineBeg = start
+			}
+			inlineEnd = end
+		}
+		prevBlank = blank
+		start = end
+	}
+	flush(len(p.src))
+}
+
+// isIndentedCode reports whether line is indented by four columns or more.
+func isIndentedCode(line []byte) bool {
+	col := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return col >= 4
+		}
+		if col >= 4 {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLabel normalizes a link label for matching, i.e. case folding and
+// collapsing whitespaces.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// scanInline finds images in the inline content of src[start:end].
+func (p *mdParser) scanInline(start, end int) {
+	src := p.src[:end]
+	for i := start; i < end; {
+		switch c := src[i]; {
+		case c == '\\':
+			i += 2
+		case c == '`':
+			i = skipCodeSpan(src, i)
+		case c == '!' && i+1 < end && src[i+1] == '[':
+			i = p.scanImage(src, i+1)
+		case c == '<':
+			i = p.scanAutolink(src, i)
+		default:
+			i++
+		}
+	}
+}
+
+// skipCodeSpan returns the index next to the code span starting at i, or to
+// the backtick string if it is not closed.
+func skipCodeSpan(src []byte, i int) int {
+	n := 0
+	for i+n < len(src) && src[i+n] == '`' {
+		n++
+	}
+	for j := i + n; j < len(src); {
+		if src[j] != '`' {
+			j++
+			continue
+		}
+		m := 0
+		for j+m < len(src) && src[j+m] == '`' {
+			m++
+		}
+		if m == n {
+			return j + m
+		}
+		j += m
+	}
+	return i + n
+}
+
+// scanImage parses an image whose link text starts at src[i] == '['.
+func (p *mdParser) scanImage(src []byte, i int) int {
+	textEnd := matchBracket(src, i)
+	if textEnd < 0 {
+		return i + 1
+	}
+	text := string(src[i+1 : textEnd])
+	j := textEnd + 1
+	switch {
+	case j < len(src) && src[j] == '(':
+		if dest, next, ok := parseInlineDest(src, j+1); ok {
+			if dest.start < dest.end {
+				p.images = append(p.images, dest)
+			}
+			return next
+		}
+	case j < len(src) && src[j] == '[':
+		if labelEnd := matchBracket(src, j); labelEnd >= 0 {
+			label := string(src[j+1 : labelEnd])
+			if strings.TrimSpace(label) == "" {
+				label = text
+			}
+			p.imageLabels[normalizeLabel(label)] = true
+			return labelEnd + 1
+		}
+	}
+	p.imageLabels[normalizeLabel(text)] = true
+	return j
+}
+
+// matchBracket returns the index of "]" matching "[" at src[i], or -1.
+func matchBracket(src []byte, i int) int {
+	depth := 0
+	for ; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '`':
+			i = skipCodeSpan(src, i) - 1
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseInlineDest parses the destination and the optional title of an inline
+// link starting next to "(" at src[i], and returns the destination span and
+// the index next to ")".
+func parseInlineDest(src []byte, i int) (mdSpan, int, bool) {
+	i = skipSpaces(src, i)
+	var dest mdSpan
+	if i < len(src) && src[i] == '<' {
+		n := bytes.IndexAny(src[i+1:], "<>\n")
+		if n < 0 || src[i+1+n] != '>' {
+			return dest, 0, false
+		}
+		dest = mdSpan{i + 1, i + 1 + n}
+		i = dest.end + 1
+	} else {
+		dest.start = i
+		for depth := 0; i < len(src); i++ {
+			c := src[i]
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c <= ' ' || (c == ')' && depth == 0) {
+				break
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				depth--
+			}
+		}
+		if i > len(src) {
+			return dest, 0, false
+		}
+		dest.end = i
+	}
+
+	j := skipSpaces(src, i)
+	if j < len(src) && j > i && (src[j] == '"' || src[j] == '\'' || src[j] == '(') {
+		closing := src[j]
+		if closing == '(' {
+			closing = ')'
+		}
+		for j++; j < len(src) && src[j] != closing; j++ {
+			if src[j] == '\\' {
+				j++
+			}
+		}
+		j = skipSpaces(src, j+1)
+	}
+	if j >= len(src) || src[j] != ')' {
+		return dest, 0, false
+	}
+	// NOTE(yosida95): escapes and entities have to be decoded to get the
+	// URL, so leave such destinations as they are.
+	if bytes.ContainsAny(src[dest.start:dest.end], "\\&") {
+		dest.end = dest.start
+	}
+	return dest, j + 1, true
+}
+
+func skipSpaces(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+var mdAutolinkRe = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.\-]{1,31}:[^\x00-\x20<>]*)>`)
+
+// imageExts are extensions of image files for autolinks to be rewritten.
+var imageExts = map[string]bool{
+	".apng": true,
+	".avif": true,
+	".bmp":  true,
+	".gif":  true,
+	".jpeg": true,
+	".jpg":  true,
+	".png":  true,
+	".svg":  true,
+	".webp": true,
+}
+
+// scanAutolink parses an autolink starting at src[i] == '<'.
+func (p *mdParser) scanAutolink(src []byte, i int) int {
+	m := mdAutolinkRe.FindSubmatchIndex(src[i:])
+	if m == nil {
+		return i + 1
+	}
+	dest := mdSpan{i + m[2], i + m[3]}
+	rawurl := string(src[dest.start:dest.end])
+	if n := strings.IndexAny(rawurl, "?#"); n >= 0 {
+		rawurl = rawurl[:n]
+	}
+	if imageExts[strings.ToLower(path.Ext(rawurl))] {
+		p.images = append(p.images, dest)
+	}
+	return i + m[1]
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rewrite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	rw := newTestRewriter(t)
+	rw.TrustedHosts = []string{"trusted.example.com"}
+	const (
+		cat = "http://example.com/cat.png"
+		dog = "https://example.com/dog.png"
+	)
+	for _, c := range []struct {
+		in   string
+		out  string
+		urls []string
+	}{
+		{
+			in:   "Look at this ![a *cat*](http://example.com/cat.png \"Cat\")!\r\n",
+			out:  "Look at this ![a *cat*]({} \"Cat\")!\r\n",
+			urls: []string{cat},
+		},
+		{
+			in:   "[![cat](<http://example.com/cat.png>)](https://example.com/) and [a link](https://example.com/dog.png)",
+			out:  "[![cat](<{}>)](https://example.com/) and [a link](https://example.com/dog.png)",
+			urls: []string{cat},
+		},
+		{
+			in:   "![cat][c] ![Dog] [link][l]\n\n[C]: http://example.com/cat.png\n  [dog]: <https://example.com/dog.png> 'Dog'\n[l]: https://example.com/dog.png\n",
+			out:  "![cat][c] ![Dog] [link][l]\n\n[C]: {}\n  [dog]: <{}> 'Dog'\n[l]: https://example.com/dog.png\n",
+			urls: []string{cat, dog},
+		},
+		{
+			in:   "<https://example.com/dog.png?size=large> <https://example.com/> <mailto:cat@example.com>",
+			out:  "<{}> <https://example.com/> <mailto:cat@example.com>",
+			urls: []string{dog + "?size=large"},
+		},
+		{
+			in:  "`![cat](http://example.com/cat.png)` and ``![`cat`](http://example.com/cat.png)``",
+			out: "`![cat](http://example.com/cat.png)` and ``![`cat`](http://example.com/cat.png)``",
+		},
+		{
+			in:   "```markdown\n![cat](http://example.com/cat.png)\n```\n~~~~\n```\n![cat](http://example.com/cat.png)\n~~~~\n\n    ![cat](http://example.com/cat.png)\n\n![dog](https://example.com/dog.png)\n",
+			out:  "```markdown\n![cat](http://example.com/cat.png)\n```\n~~~~\n```\n![cat](http://example.com/cat.png)\n~~~~\n\n    ![cat](http://example.com/cat.png)\n\n![dog]({})\n",
+			urls: []string{dog},
+		},
+		{
+			in:  "![cat](/cat.png) ![cat](https://trusted.example.com/cat.png) ![cat](http://example.com/c\\_at.png) \\![cat](http://example.com/cat.png) ![cat](http://example.com/cat.png",
+			out: "![cat](/cat.png) ![cat](https://trusted.example.com/cat.png) ![cat](http://example.com/c\\_at.png) \\![cat](http://example.com/cat.png) ![cat](http://example.com/cat.png",
+		},
+		{
+			in:   "![cat](//example.com/cat.png) ![dog][d]\n\n[d]: //example.com/dog.png\n",
+			out:  "![cat]({}) ![dog][d]\n\n[d]: {}\n",
+			urls: []string{"https://example.com/cat.png", dog},
+		},
+		{
+			in:   "![cat](http://example.com/(cat).png)",
+			out:  "![cat]({})",
+			urls: []string{"http://example.com/(cat).png"},
+		},
+	} {
+		have, err := rw.Markdown(context.Background(), []byte(c.in))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.in, err)
+			continue
+		}
+		if expected := expand(t, rw, c.out, c.urls...); string(have) != expected {
+			t.Errorf("%q: expected %q, have %q", c.in, expected, have)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rewrite rewrites URLs of images in user-generated content to signed
+// URLs of chame.
+package rewrite
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+// Rewriter rewrites URLs of images to signed URLs with Client.
+type Rewriter struct {
+	Client *chame.Client
+	// SignOption is used to sign every URL.
+	SignOption chame.SignOption
+	// TrustedHosts is a list of hosts whose URLs are left as they are. An
+	// entry starting with "." matches subdomains of the rest, such as
+	// ".example.com" for "img.example.com".
+	TrustedHosts []string
+	// InsecureOnly rewrites only "http:" URLs so as to fix mixed content,
+	// leaving "https:" URLs as they are. Protocol-relative URLs are always
+	// rewritten, resolved with "https:", or "http:" if InsecureOnly is set.
+	InsecureOnly bool
+}
+
+// signer signs URLs for a single document, signing each distinct URL once.
+type signer struct {
+	rw     *Rewriter
+	ctx    context.Context
+	signed map[string]string
+}
+
+func (rw *Rewriter) newSigner(ctx context.Context) *signer {
+	return &signer{
+		rw:     rw,
+		ctx:    ctx,
+		signed: make(map[string]string),
+	}
+}
+
+// sign returns the signed URL of rawurl. If rawurl is not to be rewritten,
+// sign returns rawurl as it is.
+func (s *signer) sign(rawurl string) (string, error) {
+	if signed, ok := s.signed[rawurl]; ok {
+		return signed, nil
+	}
+	signed := rawurl
+	if target, ok := s.rw.target(rawurl); ok {
+		var err error
+		signed, err = s.rw.Client.Sign(s.ctx, target, s.rw.SignOption)
+		if err != nil {
+			return "", err
+		}
+	}
+	s.signed[rawurl] = signed
+	return signed, nil
+}
+
+// target returns the URL to be signed for rawurl, and reports whether rawurl
+// is an HTTP(S) URL that is neither trusted nor already pointing to chame.
+// Protocol-relative URLs are resolved with "https:", or "http:" if
+// InsecureOnly is set.
+func (rw *Rewriter) target(rawurl string) (string, bool) {
+	base := rw.Client.BaseURL()
+	if isProtocolRelative(rawurl) {
+		rest := rawurl[2:]
+		if _, host, ok := strings.Cut(base, "//"); ok && strings.HasPrefix(rest, host+"/") {
+			return "", false
+		}
+		scheme := "https://"
+		if rw.InsecureOnly {
+			scheme = "http://"
+		}
+		rawurl = scheme + rest
+	}
+	if strings.HasPrefix(rawurl, base+"/") || !rw.shouldRewrite(rawurl) {
+		return "", false
+	}
+	return rawurl, true
+}
+
+// isProtocolRelative reports whether rawurl starts with "//", where browsers
+// treat backslashes as slashes.
+func isProtocolRelative(rawurl string) bool {
+	return len(rawurl) >= 2 && (rawurl[0] == '/' || rawurl[0] == '\\') && (rawurl[1] == '/' || rawurl[1] == '\\')
+}
+
+// shouldRewrite reports whether rawurl is an absolute HTTP(S) URL of an
+// untrusted host.
+func (rw *Rewriter) shouldRewrite(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+	case "https":
+		if rw.InsecureOnly {
+			return false
+		}
+	default:
+		return false
+	}
+	return !rw.isTrusted(strings.ToLower(u.Hostname()))
+}
+
+func (rw *Rewriter) isTrusted(host string) bool {
+	for _, trusted := range rw.TrustedHosts {
+		trusted = strings.ToLower(trusted)
+		if strings.HasPrefix(trusted, ".") {
+			if strings.HasSuffix(host, trusted) || host == trusted[1:] {
+				return true
+			}
+		} else if host == trusted {
+			return true
+		}
+	}
+	return false
+}
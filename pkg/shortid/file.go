@@ -0,0 +1,210 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shortid
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+// File is a ShortIDStore persisted in a file. Entries are appended to the
+// file, which is compacted when expired entries are removed.
+//
+// Each line of the file is in the following form, where expires is a Unix
+// time or 0 for entries that never expire.
+//
+//	<short ID> <expires> <token>
+type File struct {
+	table
+	path string
+	fp   *os.File
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var _ chame.ShortIDStore = (*File)(nil)
+
+// NewFile loads the file at path, which is created if it does not exist, and
+// removes expired entries every gcInterval. If gcInterval is not positive,
+// expired entries are only removed by GC.
+func NewFile(path string, gcInterval time.Duration) (*File, error) {
+	f := &File{
+		table: table{entries: make(map[string]*entry)},
+		path:  path,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	f.fp = fp
+	go runGC(f.GC, gcInterval, f.stop, f.done)
+	return f, nil
+}
+
+func (f *File) load() error {
+	fp, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(nil, 1<<20)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var expires int64
+		if len(fields) == 3 {
+			expires, err = strconv.ParseInt(fields[1], 10, 64)
+		}
+		if len(fields) != 3 || err != nil {
+			return fmt.Errorf("%s: line %d: malformed entry", f.path, lineno)
+		}
+		e := &entry{token: fields[2]}
+		if expires != 0 {
+			e.expiresAt = time.Unix(expires, 0)
+		}
+		// NOTE(yosida95): later lines take precedence as they are appended
+		// by PutToken only if they are allowed to.
+		if !e.expired(now) {
+			f.entries[fields[0]] = e
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	return nil
+}
+
+func formatEntry(id string, e *entry) string {
+	var expires int64
+	if !e.expiresAt.IsZero() {
+		expires = e.expiresAt.Unix()
+	}
+	return id + " " + strconv.FormatInt(expires, 10) + " " + e.token + "\n"
+}
+
+func (f *File) PutToken(_ context.Context, id string, token string, expiresAt time.Time) error {
+	if strings.ContainsAny(id, " \t\r\n") || id == "" || strings.ContainsAny(token, " \t\r\n") || token == "" {
+		return fmt.Errorf("chame: invalid short ID or token")
+	}
+	e := &entry{token: token, expiresAt: expiresAt}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fp == nil {
+		return os.ErrClosed
+	}
+	old := f.entries[id]
+	changed, err := f.put(id, e, time.Now())
+	if err != nil || !changed {
+		return err
+	}
+	if _, err := f.fp.WriteString(formatEntry(id, e)); err != nil {
+		f.entries[id] = old
+		if old == nil {
+			delete(f.entries, id)
+		}
+		return err
+	}
+	return f.fp.Sync()
+}
+
+func (f *File) GetToken(_ context.Context, id string) (string, error) {
+	return f.get(id, time.Now())
+}
+
+// GC removes expired entries and compacts the file, and returns the number
+// of the removed entries.
+func (f *File) GC() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fp == nil {
+		return 0, os.ErrClosed
+	}
+	n := f.gc(time.Now())
+	if n == 0 {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return n, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for id, e := range f.entries {
+		w.WriteString(formatEntry(id, e))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+	// Open the compacted file before replacing the old one so that f.fp
+	// never refers to an unlinked file.
+	fp, err := os.OpenFile(tmp.Name(), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return n, err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		fp.Close()
+		return n, err
+	}
+	f.fp.Close()
+	f.fp = fp
+	return n, nil
+}
+
+// Close stops removing expired entries periodically and closes the file.
+func (f *File) Close() error {
+	var err error
+	f.once.Do(func() {
+		close(f.stop)
+		<-f.done
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		err = f.fp.Close()
+		f.fp = nil
+	})
+	return err
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shortid provides implementations of chame.ShortIDStore.
+package shortid
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+type entry struct {
+	token     string
+	expiresAt time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// table is a map of short IDs shared by the implementations.
+type table struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+}
+
+// put maps id to token, and reports whether the entry is changed.
+func (t *table) put(id string, e *entry, now time.Time) (bool, error) {
+	if old, ok := t.entries[id]; ok && !old.expired(now) {
+		if old.token != e.token {
+			return false, chame.ErrShortIDConflict
+		}
+		if old.expiresAt.IsZero() || (!e.expiresAt.IsZero() && !e.expiresAt.After(old.expiresAt)) {
+			return false, nil
+		}
+	}
+	t.entries[id] = e
+	return true, nil
+}
+
+func (t *table) get(id string, now time.Time) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if e, ok := t.entries[id]; ok && !e.expired(now) {
+		return e.token, nil
+	}
+	return "", chame.ErrShortIDNotFound
+}
+
+// gc removes expired entries, and returns the number of them.
+func (t *table) gc(now time.Time) int {
+	n := 0
+	for id, e := range t.entries {
+		if e.expired(now) {
+			delete(t.entries, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Memory is an in-memory ShortIDStore.
+type Memory struct {
+	table
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var _ chame.ShortIDStore = (*Memory)(nil)
+
+// NewMemory returns an empty Memory, which removes expired entries every
+// gcInterval. If gcInterval is not positive, expired entries are only
+// removed by GC.
+func NewMemory(gcInterval time.Duration) *Memory {
+	m := &Memory{
+		table: table{entries: make(map[string]*entry)},
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go runGC(m.GC, gcInterval, m.stop, m.done)
+	return m
+}
+
+func (m *Memory) PutToken(_ context.Context, id string, token string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.put(id, &entry{token: token, expiresAt: expiresAt}, time.Now())
+	return err
+}
+
+func (m *Memory) GetToken(_ context.Context, id string) (string, error) {
+	return m.get(id, time.Now())
+}
+
+// GC removes expired entries, and returns the number of them.
+func (m *Memory) GC() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gc(time.Now()), nil
+}
+
+// Close stops removing expired entries periodically.
+func (m *Memory) Close() error {
+	m.once.Do(func() {
+		close(m.stop)
+		<-m.done
+	})
+	return nil
+}
+
+func runGC(gc func() (int, error), interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	if interval <= 0 {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := gc(); err != nil {
+				log.Printf("chame: failed to remove expired short IDs: %v", err)
+			}
+		}
+	}
+}
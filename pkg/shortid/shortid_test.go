@@ -0,0 +1,126 @@
+// Copyright 2026 Kohei YOSHIDA <https://yosida95.com/>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shortid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yosida95/chame/pkg/chame"
+)
+
+func testStore(t *testing.T, store chame.ShortIDStore, gc func() (int, error)) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	for _, c := range []struct {
+		id, token string
+		expiresAt time.Time
+		err       error
+	}{
+		{id: "forever", token: "a.b.c"},
+		{id: "valid", token: "d.e.f", expiresAt: now.Add(time.Hour)},
+		{id: "expired", token: "g.h.i", expiresAt: now.Add(-time.Second)},
+		{id: "valid", token: "d.e.f", expiresAt: now.Add(2 * time.Hour)},
+		{id: "valid", token: "j.k.l", err: chame.ErrShortIDConflict},
+		{id: "expired", token: "m.n.o", expiresAt: now.Add(-time.Second)},
+	} {
+		if err := store.PutToken(ctx, c.id, c.token, c.expiresAt); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, have %v", c.id, c.err, err)
+		}
+	}
+
+	for _, c := range []struct {
+		id, token string
+		err       error
+	}{
+		{id: "forever", token: "a.b.c"},
+		{id: "valid", token: "d.e.f"},
+		{id: "expired", err: chame.ErrShortIDNotFound},
+		{id: "unknown", err: chame.ErrShortIDNotFound},
+	} {
+		token, err := store.GetToken(ctx, c.id)
+		if token != c.token || !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %q (%v), have %q (%v)", c.id, c.token, c.err, token, err)
+		}
+	}
+
+	if n, err := gc(); n != 1 || err != nil {
+		t.Errorf("expected 1 entry removed, have %d (%v)", n, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(0)
+	defer m.Close()
+	testStore(t, m, m.GC)
+	if len(m.entries) != 2 {
+		t.Errorf("expected 2 entries, have %d", len(m.entries))
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shortids")
+	f, err := NewFile(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testStore(t, f, f.GC)
+	// entries put after compaction are written into the compacted file
+	if err := f.PutToken(context.Background(), "after", "p.q.r", time.Time{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Errorf("expected 3 lines after compaction, have %d: %q", n, data)
+	}
+
+	f, err = NewFile(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	if token, err := f.GetToken(context.Background(), "valid"); token != "d.e.f" || err != nil {
+		t.Errorf("expected %q, have %q (%v)", "d.e.f", token, err)
+	}
+	if token, err := f.GetToken(context.Background(), "after"); token != "p.q.r" || err != nil {
+		t.Errorf("expected %q, have %q (%v)", "p.q.r", token, err)
+	}
+	if err := f.PutToken(context.Background(), "bad id", "a.b.c", time.Time{}); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}
+
+func TestNewFile_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shortids")
+	if err := os.WriteFile(path, []byte("id never a.b.c\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path, time.Minute); err == nil {
+		t.Errorf("expected error not occurred")
+	}
+}